@@ -6,6 +6,7 @@ import (
 
 	"github.com/cupcakearmy/autorestic/internal"
 	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
 	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/spf13/cobra"
 )
@@ -15,13 +16,12 @@ var backupCmd = &cobra.Command{
 	Short: "Create backups for given locations",
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
 
 		selected, err := internal.GetAllOrSelected(cmd, false)
 		CheckErr(err)
-		errors := 0
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		var backupJobs []internal.BackupJob
+		var lockKeys []string
 		for _, name := range selected {
 			var splitted = strings.Split(name, "@")
 			var specificBackend = ""
@@ -29,9 +29,23 @@ var backupCmd = &cobra.Command{
 				specificBackend = splitted[1]
 			}
 			location, _ := internal.GetLocation(splitted[0])
-			errs := location.Backup(false, specificBackend)
-			for _, err := range errs {
+			backupJobs = append(backupJobs, internal.BackupJob{Location: location, Backend: specificBackend})
+			lockKeys = append(lockKeys, location.LockKeys(specificBackend)...)
+		}
+		CheckErr(lock.Acquire(lockKeys...))
+		defer lock.Release(lockKeys...)
+		results := internal.RunBackups(backupJobs, jobs, false)
+
+		// Report all errors at the end
+		errors := 0
+		for _, result := range results {
+			if len(result.Errors) == 0 {
+				continue
+			}
+			colors.Secondary.Printf("\nLocation \"%s\" failed with %d errors:\n", result.Job.Location.Name(), len(result.Errors))
+			for _, err := range result.Errors {
 				colors.Error.Printf("%s\n\n", err)
+				events.EmitError(err, result.Job.Location.Name(), result.Job.Backend)
 				errors++
 			}
 		}
@@ -44,4 +58,5 @@ var backupCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(backupCmd)
 	internal.AddFlagsToCommand(backupCmd, false)
+	backupCmd.Flags().IntP("jobs", "j", 1, "number of locations to back up at the same time")
 }
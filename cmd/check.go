@@ -11,10 +11,13 @@ var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check if everything is setup",
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
+		config := internal.GetConfig()
+		var lockKeys []string
+		for name := range config.Backends {
+			lockKeys = append(lockKeys, lock.BackendKey(name))
+		}
+		CheckErr(lock.Acquire(lockKeys...))
+		defer lock.Release(lockKeys...)
 
 		CheckErr(internal.CheckConfig())
 
@@ -3,21 +3,24 @@ package cmd
 import (
 	"github.com/cupcakearmy/autorestic/internal"
 	"github.com/cupcakearmy/autorestic/internal/flags"
-	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/spf13/cobra"
 )
 
 var cronCmd = &cobra.Command{
 	Use:   "cron",
 	Short: "Run cron job for automated backups",
-	Long:  `Intended to be mainly triggered by an automated system like systemd or crontab. For each location checks if a cron backup is due and runs it.`,
+	Long:  `Intended to be mainly triggered by an automated system like systemd or crontab. For each location checks if a cron backup is due and runs it. With --daemon it keeps running and schedules the backups itself.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
 
-		err = internal.RunCron()
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		daemon, _ := cmd.Flags().GetBool("daemon")
+		if daemon {
+			CheckErr(internal.RunCronDaemon(jobs))
+			return
+		}
+
+		err := internal.RunCron(jobs)
 		CheckErr(err)
 	},
 }
@@ -25,4 +28,6 @@ var cronCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(cronCmd)
 	cronCmd.Flags().BoolVar(&flags.CRON_LEAN, "lean", false, "only output information about actual backups")
+	cronCmd.Flags().Bool("daemon", false, "keep running and trigger backups when they are due")
+	cronCmd.Flags().IntP("jobs", "j", 1, "number of locations to back up at the same time")
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [snapshot id] [snapshot id]",
+	Short: "Show what changed between two snapshots of a location",
+	Long:  `Show what changed between two snapshots of a location. Without snapshot ids the two most recent snapshots are compared, with one id that snapshot is compared to the most recent one.`,
+	Args:  cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if asJSON {
+			// Keep stdout clean for the json
+			colors.SetOutput(os.Stderr)
+		}
+		internal.GetConfig()
+
+		location, _ := cmd.Flags().GetString("location")
+		l, ok := internal.GetLocation(location)
+		if !ok {
+			CheckErr(fmt.Errorf("invalid location \"%s\"", location))
+		}
+		from, _ := cmd.Flags().GetString("from")
+		diff, err := l.Diff(from, args)
+		CheckErr(err)
+
+		if events.Enabled() {
+			events.Emit(events.Event{Type: events.Diff, Location: l.Name(), Backend: diff.Backend, Data: diff})
+			return
+		}
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			CheckErr(encoder.Encode(diff))
+			return
+		}
+
+		colors.PrimaryPrint("Diff of location \"%s\"", l.Name())
+		colors.Secondary.Printf("%s (%s) → %s (%s) on backend \"%s\"\n\n",
+			diff.From.ShortID, diff.From.Time.Local().Format("2006-01-02 15:04:05"),
+			diff.To.ShortID, diff.To.Time.Local().Format("2006-01-02 15:04:05"),
+			diff.Backend,
+		)
+		if summary, _ := cmd.Flags().GetBool("summary"); !summary {
+			for _, path := range diff.Added {
+				colors.Success.Println("+ " + path)
+			}
+			for _, path := range diff.Removed {
+				colors.Error.Println("- " + path)
+			}
+			for _, path := range diff.Modified {
+				colors.Body.Println("M " + path)
+			}
+			colors.Body.Println("")
+		}
+		colors.Body.Printf("Added:    %d\n", len(diff.Added))
+		colors.Body.Printf("Removed:  %d\n", len(diff.Removed))
+		colors.Body.Printf("Modified: %d\n", len(diff.Modified))
+		colors.Body.Printf("Size:     +%s / -%s\n", metadata.FormatBytes(diff.AddedBytes), metadata.FormatBytes(diff.RemovedBytes))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringP("location", "l", "", "Location to compare snapshots of")
+	diffCmd.MarkFlagRequired("location")
+	diffCmd.Flags().String("from", "", "Which backend to use")
+	diffCmd.Flags().Bool("summary", false, "only show the number of changed paths and the size change")
+	diffCmd.Flags().Bool("json", false, "print the diff as json")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/spf13/cobra"
+)
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump [snapshot id] <path>",
+	Short: "Write a single file of a snapshot to stdout or a file",
+	Long:  `Write a single file of a snapshot of a location to stdout or a file. Directories are written as a tar (or zip) archive. Without a snapshot id the most recent snapshot is used.`,
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+		if out == "" {
+			if events.Enabled() {
+				CheckErr(fmt.Errorf("dumping to stdout is not possible with json output, use --out"))
+			}
+			// Keep stdout clean for the file
+			colors.SetOutput(os.Stderr)
+		}
+		internal.GetConfig()
+
+		location, _ := cmd.Flags().GetString("location")
+		l, ok := internal.GetLocation(location)
+		if !ok {
+			CheckErr(fmt.Errorf("invalid location \"%s\"", location))
+		}
+		from, _ := cmd.Flags().GetString("from")
+		archive, _ := cmd.Flags().GetString("archive")
+		force, _ := cmd.Flags().GetBool("force")
+		snapshot := ""
+		path := args[0]
+		if len(args) > 1 {
+			snapshot = args[0]
+			path = args[1]
+		}
+
+		var w io.Writer = os.Stdout
+		if out != "" {
+			flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if force {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			f, err := os.OpenFile(out, flag, 0644)
+			if os.IsExist(err) {
+				err = fmt.Errorf("%s already exists, use --force to overwrite it", out)
+			}
+			CheckErr(err)
+			defer f.Close()
+			w = f
+		}
+
+		s, err := l.Dump(from, snapshot, path, archive, w)
+		if err != nil && out != "" {
+			os.Remove(out)
+		}
+		CheckErr(err)
+		if out != "" {
+			colors.Success.Printf("Dumped %s of snapshot %s (%s) from backend \"%s\" to %s\n", path, s.ShortID, s.Time.Local().Format("2006-01-02 15:04:05"), s.Backend, out)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dumpCmd)
+	dumpCmd.Flags().StringP("location", "l", "", "Location to dump from")
+	dumpCmd.MarkFlagRequired("location")
+	dumpCmd.Flags().String("from", "", "Which backend to use")
+	dumpCmd.Flags().StringP("out", "O", "", "write to this file instead of stdout")
+	dumpCmd.Flags().BoolP("force", "f", false, "overwrite the file given with --out")
+	dumpCmd.Flags().String("archive", "", "archive format for directories, either \"tar\" or \"zip\"")
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/cupcakearmy/autorestic/internal"
 	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/history"
 	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/spf13/cobra"
 )
@@ -14,19 +16,24 @@ var execCmd = &cobra.Command{
 	Short: "Execute arbitrary native restic commands for given backends",
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
 
 		selected, err := internal.GetAllOrSelected(cmd, true)
 		CheckErr(err)
+		var lockKeys []string
+		for _, name := range selected {
+			lockKeys = append(lockKeys, lock.BackendKey(name))
+		}
+		CheckErr(lock.Acquire(lockKeys...))
+		defer lock.Release(lockKeys...)
 
 		var errors []error
 		for _, name := range selected {
 			colors.PrimaryPrint("  Executing on \"%s\"  ", name)
+			events.Emit(events.Event{Type: events.BackendStarted, Operation: history.OperationExec, Backend: name})
 			backend, _ := internal.GetBackend(name)
 			err := backend.Exec(args)
 			if err != nil {
+				events.EmitError(err, "", name)
 				errors = append(errors, err)
 			}
 		}
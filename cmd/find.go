@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var findCmd = &cobra.Command{
+	Use:   "find <pattern...>",
+	Short: "Find files in the snapshots of a location",
+	Long:  `Find files in the snapshots of a location. Patterns are matched against file names and may contain wildcards, e.g. "*.conf". Without --from all backends of the location are searched.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if asJSON {
+			// Keep stdout clean for the json
+			colors.SetOutput(os.Stderr)
+		}
+		internal.GetConfig()
+
+		location, _ := cmd.Flags().GetString("location")
+		l, ok := internal.GetLocation(location)
+		if !ok {
+			CheckErr(fmt.Errorf("invalid location \"%s\"", location))
+		}
+		from, _ := cmd.Flags().GetString("from")
+		matches, findErr := l.Find(from, args)
+
+		if events.Enabled() {
+			for _, match := range matches {
+				events.Emit(events.Event{Type: events.Match, Location: l.Name(), Backend: match.Snapshot.Backend, Data: match})
+			}
+		} else if asJSON {
+			if matches == nil {
+				matches = []internal.Match{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			CheckErr(encoder.Encode(matches))
+		} else if len(matches) == 0 {
+			colors.Faint.Println("No matches found.")
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SNAPSHOT\tTIME\tBACKEND\tTYPE\tSIZE\tMODIFIED\tPATH")
+			for _, match := range matches {
+				size := ""
+				if match.Type == "file" {
+					size = metadata.FormatBytes(match.Size)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					match.Snapshot.ShortID,
+					match.Snapshot.Time.Local().Format("2006-01-02 15:04:05"),
+					match.Snapshot.Backend,
+					match.Type,
+					size,
+					match.ModTime.Local().Format("2006-01-02 15:04:05"),
+					match.Path,
+				)
+			}
+			w.Flush()
+		}
+		CheckErr(findErr)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(findCmd)
+	findCmd.Flags().StringP("location", "l", "", "Location to search in")
+	findCmd.MarkFlagRequired("location")
+	findCmd.Flags().String("from", "", "Which backend to search, defaults to all backends of the location")
+	findCmd.Flags().Bool("json", false, "print the matches as json")
+}
@@ -11,12 +11,17 @@ var forgetCmd = &cobra.Command{
 	Short: "Forget and optionally prune snapshots according the specified policies",
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
 
 		selected, err := internal.GetAllOrSelected(cmd, false)
 		CheckErr(err)
+		var lockKeys []string
+		for _, name := range selected {
+			location, _ := internal.GetLocation(name)
+			lockKeys = append(lockKeys, location.LockKeys("")...)
+		}
+		CheckErr(lock.Acquire(lockKeys...))
+		defer lock.Release(lockKeys...)
+
 		prune, _ := cmd.Flags().GetBool("prune")
 		dry, _ := cmd.Flags().GetBool("dry-run")
 		for _, name := range selected {
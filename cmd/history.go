@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past backup, forget and check runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.GetConfig()
+
+		locations, _ := cmd.Flags().GetStringSlice("location")
+		var since time.Time
+		if s, _ := cmd.Flags().GetString("since"); s != "" {
+			var err error
+			since, err = history.ParseSince(s, time.Now())
+			CheckErr(err)
+		}
+
+		runs, err := history.Read()
+		CheckErr(err)
+		runs = history.Filter(runs, locations, since)
+
+		if events.Enabled() {
+			for _, run := range runs {
+				events.Emit(events.Event{Type: events.Run, Operation: run.Operation, Location: run.Location, Backend: run.Backend, Data: run})
+			}
+			return
+		}
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if asJSON {
+			if runs == nil {
+				runs = []history.Run{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			CheckErr(encoder.Encode(runs))
+			return
+		}
+
+		if len(runs) == 0 {
+			colors.Faint.Println("No backups recorded.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "START\tOPERATION\tLOCATION\tBACKEND\tDURATION\tSTATUS\tSNAPSHOT\tADDED\tFILES (NEW/CHANGED/UNMODIFIED)")
+		for _, run := range runs {
+			status := colors.Success.Sprint("ok")
+			if !run.Success() {
+				status = colors.Error.Sprintf("failed (%d)", run.ExitCode)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d/%d\n",
+				run.Start.Local().Format("2006-01-02 15:04:05"),
+				run.Operation,
+				run.Location,
+				run.Backend,
+				metadata.FormatDuration(run.Duration()),
+				status,
+				run.SnapshotID,
+				metadata.FormatBytes(run.AddedBytes),
+				run.FilesNew, run.FilesChanged, run.FilesUnmodified,
+			)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringSliceP("location", "l", []string{}, "only show runs of the given locations")
+	historyCmd.Flags().String("since", "", "only show runs since a date (e.g. 2006-01-02) or duration (e.g. 36h, 7d)")
+	historyCmd.Flags().Bool("json", false, "print runs as json")
+}
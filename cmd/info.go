@@ -9,10 +9,12 @@ var infoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "Show info about the config",
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.GetConfig().Describe()
+		resolved, _ := cmd.Flags().GetBool("resolved")
+		internal.GetConfig().Describe(resolved)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().Bool("resolved", false, "show locations and backends with the templates they extend applied")
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/cupcakearmy/autorestic/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Initialize the repositories of backends, generating keys where needed",
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.GetConfig()
+
+		selected, err := internal.GetAllOrSelected(cmd, true)
+		CheckErr(err)
+		sink, _ := cmd.Flags().GetString("key-sink")
+		keyFile, _ := cmd.Flags().GetString("key-file")
+		if keyFile != "" {
+			if !cmd.Flags().Changed("key-sink") {
+				sink = string(internal.KeySinkFile)
+			} else if sink != string(internal.KeySinkFile) {
+				CheckErr(fmt.Errorf("--key-file can only be used with --key-sink file"))
+			}
+			if len(selected) > 1 {
+				CheckErr(fmt.Errorf("--key-file can only be used for a single backend"))
+			}
+		}
+		var lockKeys []string
+		for _, name := range selected {
+			lockKeys = append(lockKeys, lock.BackendKey(name))
+		}
+		CheckErr(lock.Acquire(lockKeys...))
+		defer lock.Release(lockKeys...)
+
+		var errors []error
+		for _, name := range selected {
+			colors.PrimaryPrint("  Initializing \"%s\"  ", name)
+			events.Emit(events.Event{Type: events.BackendStarted, Operation: history.OperationInit, Backend: name})
+			backend, _ := internal.GetBackend(name)
+			err := backend.Init(internal.InitOptions{
+				Sink:    internal.KeySink(sink),
+				KeyFile: keyFile,
+			})
+			if err != nil {
+				events.EmitError(err, "", name)
+				errors = append(errors, err)
+			}
+		}
+
+		if len(errors) > 0 {
+			for _, err := range errors {
+				colors.Error.Printf("%s\n\n", err)
+			}
+
+			CheckErr(fmt.Errorf("%d errors were found", len(errors)))
+		}
+		colors.Success.Println("Done")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	internal.AddFlagsToCommand(initCmd, true)
+	initCmd.Flags().String("key-sink", string(internal.KeySinkConfig), `where generated keys are stored: "config", "env" (.autorestic.env) or "file"`)
+	initCmd.Flags().String("key-file", "", "path of the key file, relative to the config (default <backend>.key)")
+}
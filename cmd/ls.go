@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [snapshot id] [path...]",
+	Short: "List the files in a snapshot of a location",
+	Long:  `List the files in a snapshot of a location. Without a snapshot id the most recent snapshot is listed. Use "latest" to pass paths for the most recent snapshot.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if asJSON {
+			// Keep stdout clean for the json
+			colors.SetOutput(os.Stderr)
+		}
+		internal.GetConfig()
+
+		location, _ := cmd.Flags().GetString("location")
+		l, ok := internal.GetLocation(location)
+		if !ok {
+			CheckErr(fmt.Errorf("invalid location \"%s\"", location))
+		}
+		from, _ := cmd.Flags().GetString("from")
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		snapshot := ""
+		var paths []string
+		if len(args) > 0 {
+			snapshot = args[0]
+			paths = args[1:]
+		}
+		listing, err := l.Ls(from, snapshot, paths, recursive)
+		CheckErr(err)
+
+		if events.Enabled() {
+			events.Emit(events.Event{Type: events.Listing, Location: l.Name(), Backend: listing.Snapshot.Backend, Data: listing})
+			return
+		}
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			CheckErr(encoder.Encode(listing))
+			return
+		}
+
+		colors.PrimaryPrint("Snapshot %s of location \"%s\"", listing.Snapshot.ShortID, l.Name())
+		colors.Secondary.Printf("Taken %s, on backend \"%s\"\n\n", listing.Snapshot.Time.Local().Format("2006-01-02 15:04:05"), listing.Snapshot.Backend)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tSIZE\tMODIFIED\tPATH")
+		for _, node := range listing.Nodes {
+			size := ""
+			if node.Type == "file" {
+				size = metadata.FormatBytes(node.Size)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", node.Type, size, node.ModTime.Local().Format("2006-01-02 15:04:05"), node.Path)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+	lsCmd.Flags().StringP("location", "l", "", "Location to list the files of")
+	lsCmd.MarkFlagRequired("location")
+	lsCmd.Flags().String("from", "", "Which backend to use")
+	lsCmd.Flags().BoolP("recursive", "r", false, "also list the contents of subdirectories of the given paths")
+	lsCmd.Flags().Bool("json", false, "print the files as json")
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/history"
 	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/spf13/cobra"
 )
@@ -14,9 +17,6 @@ var restoreCmd = &cobra.Command{
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
-		err := lock.Lock()
-		CheckErr(err)
-		defer lock.Unlock()
 
 		location, _ := cmd.Flags().GetString("location")
 		l, ok := internal.GetLocation(location)
@@ -25,11 +25,26 @@ var restoreCmd = &cobra.Command{
 		}
 		target, _ := cmd.Flags().GetString("to")
 		from, _ := cmd.Flags().GetString("from")
+		lockKeys := l.LockKeys(from)
+		err := lock.Acquire(lockKeys...)
+		CheckErr(err)
+		defer lock.Release(lockKeys...)
 		force, _ := cmd.Flags().GetBool("force")
 		snapshot := ""
 		if len(args) > 0 {
 			snapshot = args[0]
 		}
+		if at, _ := cmd.Flags().GetString("at"); at != "" {
+			if snapshot != "" {
+				CheckErr(fmt.Errorf("either pass a snapshot id or --at, not both"))
+			}
+			t, err := history.ParseSince(at, time.Now())
+			CheckErr(err)
+			s, err := l.SnapshotAt(from, t)
+			CheckErr(err)
+			colors.Secondary.Printf("Using snapshot %s taken at %s\n", s.ShortID, s.Time.Local().Format("2006-01-02 15:04:05"))
+			snapshot = s.ID
+		}
 
 		// Get optional flags
 		optional := []string{}
@@ -42,7 +57,19 @@ var restoreCmd = &cobra.Command{
 			}
 		}
 
-		err = l.Restore(target, from, force, snapshot, optional)
+		dry, _ := cmd.Flags().GetBool("dry-run")
+		verify, _ := cmd.Flags().GetBool("verify")
+		toVolume, _ := cmd.Flags().GetString("to-volume")
+		err = l.Restore(internal.RestoreOptions{
+			To:       target,
+			From:     from,
+			Snapshot: snapshot,
+			Force:    force,
+			DryRun:   dry,
+			Verify:   verify,
+			ToVolume: toVolume,
+			Args:     optional,
+		})
 		CheckErr(err)
 	},
 }
@@ -52,7 +79,11 @@ func init() {
 	restoreCmd.Flags().BoolP("force", "f", false, "Force, target folder will be overwritten")
 	restoreCmd.Flags().String("from", "", "Which backend to use")
 	restoreCmd.Flags().String("to", "", "Where to restore the data")
+	restoreCmd.Flags().String("to-volume", "", "restore a volume location into this docker volume instead of its own, created if missing")
+	restoreCmd.Flags().Bool("dry-run", false, "only show which files would be restored and how much data, requires restic 0.17 or newer")
+	restoreCmd.Flags().Bool("verify", false, "verify the content of the restored files against the snapshot")
 	restoreCmd.Flags().StringP("location", "l", "", "Location to be restored")
+	restoreCmd.Flags().String("at", "", "restore the most recent snapshot taken at or before a date (e.g. \"2006-01-02 15:04\") or duration ago (e.g. 36h, 7d)")
 	restoreCmd.MarkFlagRequired("location")
 
 	// Passed on flags
@@ -4,9 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/cupcakearmy/autorestic/internal"
 	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
 	"github.com/cupcakearmy/autorestic/internal/flags"
 	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/spf13/cobra"
@@ -18,18 +20,28 @@ import (
 func CheckErr(err error) {
 	if err != nil {
 		colors.Error.Fprintln(os.Stderr, "Error:", err)
-		lock.Unlock()
+		events.EmitError(err, "", "")
+		events.EmitSummary(commandName, commandStart)
+		lock.ReleaseAll()
 		os.Exit(1)
 	}
 }
 
 var cfgFile string
+var commandName string
+var commandStart = time.Now()
 
 var rootCmd = &cobra.Command{
 	Version: internal.VERSION,
 	Use:     "autorestic",
 	Short:   "CLI Wrapper for restic",
 	Long:    "Documentation:\thttps://autorestic.vercel.app\nSupport:\thttps://discord.gg/wS7RpYTYd2",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		commandName = cmd.Name()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		events.EmitSummary(commandName, commandStart)
+	},
 }
 
 func Execute() {
@@ -41,11 +53,24 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&flags.CI, "ci", false, "CI mode disabled interactive mode and colors and enables verbosity")
 	rootCmd.PersistentFlags().BoolVarP(&flags.VERBOSE, "verbose", "v", false, "verbose mode")
 	rootCmd.PersistentFlags().StringVar(&flags.RESTIC_BIN, "restic-bin", "restic", "specify custom restic binary")
+	rootCmd.PersistentFlags().StringVarP(&flags.OUTPUT, "output", "o", flags.OutputText, "output format, either \"text\" or \"json\" (newline delimited events)")
+	rootCmd.PersistentFlags().BoolVar(&flags.LOCK_WAIT, "wait", false, "wait for locks held by other instances instead of failing")
+	rootCmd.PersistentFlags().DurationVar(&flags.LOCK_TIMEOUT, "lock-timeout", 0, "wait at most this long for locks held by other instances, implies --wait")
 	rootCmd.PersistentFlags().StringVar(&flags.DOCKER_IMAGE, "docker-image", "cupcakearmy/autorestic:"+internal.VERSION, "specify a custom docker image")
 	cobra.OnInitialize(initConfig)
 }
 
 func initConfig() {
+	switch flags.OUTPUT {
+	case flags.OutputText:
+	case flags.OutputJSON:
+		// Keep stdout clean for the json events
+		colors.SetOutput(os.Stderr)
+	default:
+		colors.Error.Printf("invalid output format \"%s\"\n", flags.OUTPUT)
+		os.Exit(1)
+	}
+
 	if ci, _ := rootCmd.Flags().GetBool("ci"); ci {
 		colors.DisableColors(true)
 		flags.VERBOSE = true
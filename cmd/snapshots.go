@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cupcakearmy/autorestic/internal"
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List the snapshots of locations across all their backends",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		if asJSON {
+			// Keep stdout clean for the json
+			colors.SetOutput(os.Stderr)
+		}
+		internal.GetConfig()
+
+		selected, err := internal.GetAllOrSelected(cmd, false)
+		CheckErr(err)
+
+		var snapshots []internal.Snapshot
+		var errors []error
+		for _, name := range selected {
+			location, _ := internal.GetLocation(name)
+			s, err := location.Snapshots()
+			if err != nil {
+				errors = append(errors, err)
+			}
+			snapshots = append(snapshots, s...)
+		}
+
+		if events.Enabled() {
+			for _, s := range snapshots {
+				events.Emit(events.Event{Type: events.Snapshot, Location: s.Location, Backend: s.Backend, Data: s})
+			}
+		} else if asJSON {
+			if snapshots == nil {
+				snapshots = []internal.Snapshot{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			CheckErr(encoder.Encode(snapshots))
+		} else {
+			printSnapshots(selected, snapshots)
+		}
+
+		if len(errors) > 0 {
+			for _, err := range errors {
+				colors.Error.Printf("%s\n\n", err)
+			}
+			CheckErr(fmt.Errorf("%d errors were found", len(errors)))
+		}
+	},
+}
+
+func printSnapshots(locations []string, snapshots []internal.Snapshot) {
+	for _, location := range locations {
+		colors.PrimaryPrint("Snapshots of location \"%s\"", location)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tHOST\tBACKEND\tPATHS\tSIZE")
+		count := 0
+		for _, s := range snapshots {
+			if s.Location != location {
+				continue
+			}
+			size := ""
+			if s.Size > 0 {
+				size = metadata.FormatBytes(s.Size)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.ShortID,
+				s.Time.Local().Format("2006-01-02 15:04:05"),
+				s.Hostname,
+				s.Backend,
+				strings.Join(s.Paths, ", "),
+				size,
+			)
+			count++
+		}
+		w.Flush()
+		colors.Faint.Printf("%d snapshots\n", count)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotsCmd)
+	internal.AddFlagsToCommand(snapshotsCmd, false)
+	snapshotsCmd.Flags().Bool("json", false, "print snapshots as json")
+}
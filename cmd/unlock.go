@@ -1,10 +1,7 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
-	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/cupcakearmy/autorestic/internal"
@@ -17,13 +14,38 @@ var unlockCmd = &cobra.Command{
 	Use:   "unlock",
 	Short: "Unlock autorestic only if you are sure that no other instance is running",
 	Long: `Unlock autorestic only if you are sure that no other instance is running.
-To check you can run "ps aux | grep autorestic".`,
+Shows who is holding each lock and whether the instance is still running.
+Locks of instances that are not running anymore are removed without asking.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.GetConfig()
 
 		force, _ := cmd.Flags().GetBool("force")
 
-		if !force && isAutoresticRunning() {
+		holders, err := lock.List()
+		if err != nil {
+			colors.Error.Println("Could not read locks:", err)
+			return
+		}
+		if len(holders) == 0 {
+			colors.Success.Println("Nothing is locked")
+			return
+		}
+
+		var running []lock.Holder
+		for _, holder := range holders {
+			if !holder.Stale {
+				colors.Body.Printf("%s is locked by %s [running]\n", lock.DescribeKey(holder.Key), holder)
+				running = append(running, holder)
+				continue
+			}
+			colors.Body.Printf("%s is locked by %s [stale]\n", lock.DescribeKey(holder.Key), holder)
+			if err := lock.Remove(holder.Key); err != nil {
+				colors.Error.Println("Could not unlock:", err)
+				return
+			}
+		}
+
+		if !force && len(running) > 0 {
 			colors.Error.Print("Another autorestic instance is running. Are you sure you want to unlock? (yes/no): ")
 			var response string
 			fmt.Scanln(&response)
@@ -33,10 +55,11 @@ To check you can run "ps aux | grep autorestic".`,
 			}
 		}
 
-		err := lock.Unlock()
-		if err != nil {
-			colors.Error.Println("Could not unlock:", err)
-			return
+		for _, holder := range running {
+			if err := lock.Remove(holder.Key); err != nil {
+				colors.Error.Println("Could not unlock:", err)
+				return
+			}
 		}
 
 		colors.Success.Println("Unlock successful")
@@ -47,35 +70,3 @@ func init() {
 	rootCmd.AddCommand(unlockCmd)
 	unlockCmd.Flags().Bool("force", false, "force unlock")
 }
-
-// isAutoresticRunning checks if autorestic is running
-// and returns true if it is.
-// It also prints the processes to stdout.
-func isAutoresticRunning() bool {
-	cmd := exec.Command("sh", "-c", "ps aux | grep autorestic")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		return false
-	}
-
-	lines := strings.Split(out.String(), "\n")
-	autoresticProcesses := []string{}
-	currentPid := fmt.Sprint(os.Getpid())
-
-	for _, line := range lines {
-		if strings.Contains(line, "autorestic") && !strings.Contains(line, "grep autorestic") && !strings.Contains(line, currentPid) {
-			autoresticProcesses = append(autoresticProcesses, line)
-		}
-	}
-
-	if len(autoresticProcesses) > 0 {
-		colors.Faint.Println("Found autorestic processes:")
-		for _, proc := range autoresticProcesses {
-			colors.Faint.Println(proc)
-		}
-		return true
-	}
-	return false
-}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/cupcakearmy/autorestic/internal/colors"
@@ -14,19 +16,23 @@ import (
 )
 
 type BackendRest struct {
-	User     string `mapstructure:"user,omitempty" yaml:"user,omitempty"`
-	Password string `mapstructure:"password,omitempty" yaml:"password,omitempty"`
+	User     string `mapstructure:"user,omitempty" yaml:"user,omitempty" json:"user,omitempty"`
+	Password string `mapstructure:"password,omitempty" yaml:"password,omitempty" json:"password,omitempty"`
 }
 
 type Backend struct {
 	name       string
-	Type       string            `mapstructure:"type,omitempty" yaml:"type,omitempty"`
-	Path       string            `mapstructure:"path,omitempty" yaml:"path,omitempty"`
-	Key        string            `mapstructure:"key,omitempty" yaml:"key,omitempty"`
-	RequireKey bool              `mapstructure:"requireKey,omitempty" yaml:"requireKey,omitempty"`
-	Env        map[string]string `mapstructure:"env,omitempty" yaml:"env,omitempty"`
-	Rest       BackendRest       `mapstructure:"rest,omitempty" yaml:"rest,omitempty"`
-	Options    Options           `mapstructure:"options,omitempty" yaml:"options,omitempty"`
+	Extends    string            `mapstructure:"extends,omitempty" yaml:"extends,omitempty" json:"extends,omitempty"`
+	Type       string            `mapstructure:"type,omitempty" yaml:"type,omitempty" json:"type,omitempty"`
+	Path       string            `mapstructure:"path,omitempty" yaml:"path,omitempty" json:"path,omitempty"`
+	Key        string            `mapstructure:"key,omitempty" yaml:"key,omitempty" json:"key,omitempty"`
+	KeyFile    string            `mapstructure:"keyFile,omitempty" yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	KeyCommand string            `mapstructure:"keyCommand,omitempty" yaml:"keyCommand,omitempty" json:"keyCommand,omitempty"`
+	RequireKey bool              `mapstructure:"requireKey,omitempty" yaml:"requireKey,omitempty" json:"requireKey,omitempty"`
+	Env        map[string]string `mapstructure:"env,omitempty" yaml:"env,omitempty" json:"env,omitempty"`
+	Rest       BackendRest       `mapstructure:"rest,omitempty" yaml:"rest,omitempty" json:"rest,omitempty"`
+	Options    Options           `mapstructure:"options,omitempty" yaml:"options,omitempty" json:"options,omitempty"`
+	Jobs       int               `mapstructure:"jobs,omitempty" yaml:"jobs,omitempty" json:"jobs,omitempty"`
 }
 
 func GetBackend(name string) (Backend, bool) {
@@ -64,8 +70,17 @@ var nonAlphaRegex = regexp.MustCompile("[^A-Za-z0-9]")
 func (b Backend) getEnv() (map[string]string, error) {
 	env := make(map[string]string)
 	// Key
-	if b.Key != "" {
+	switch {
+	case b.Key != "":
 		env["RESTIC_PASSWORD"] = b.Key
+	case b.KeyFile != "":
+		file, err := GetPathRelativeToConfig(b.KeyFile)
+		if err != nil {
+			return env, err
+		}
+		env["RESTIC_PASSWORD_FILE"] = file
+	case b.KeyCommand != "":
+		env["RESTIC_PASSWORD_COMMAND"] = b.KeyCommand
 	}
 
 	// From config file
@@ -76,9 +91,7 @@ func (b Backend) getEnv() (map[string]string, error) {
 	}
 
 	// From Envfile and passed as env
-	nameForEnv := strings.ToUpper(b.name)
-	nameForEnv = nonAlphaRegex.ReplaceAllString(nameForEnv, "_")
-	var prefix = "AUTORESTIC_" + nameForEnv + "_"
+	var prefix = b.getEnvPrefix()
 	for _, variable := range os.Environ() {
 		var splitted = strings.SplitN(variable, "=", 2)
 		if strings.HasPrefix(splitted[0], prefix) {
@@ -89,6 +102,13 @@ func (b Backend) getEnv() (map[string]string, error) {
 	return env, err
 }
 
+// getEnvPrefix returns the prefix of the env variables for the backend, e.g. AUTORESTIC_FOO_.
+func (b Backend) getEnvPrefix() string {
+	nameForEnv := strings.ToUpper(b.name)
+	nameForEnv = nonAlphaRegex.ReplaceAllString(nameForEnv, "_")
+	return "AUTORESTIC_" + nameForEnv + "_"
+}
+
 func generateRandomKey() string {
 	b := make([]byte, 64)
 	rand.Read(b)
@@ -99,80 +119,155 @@ func generateRandomKey() string {
 	return key
 }
 
-func (b Backend) validate() error {
+// Env variables restic reads the key of a repository from
+var keyEnvs = []string{"RESTIC_PASSWORD", "RESTIC_PASSWORD_FILE", "RESTIC_PASSWORD_COMMAND"}
+
+// hasKey returns whether the env of a backend provides a key in any way.
+func hasKey(env map[string]string) bool {
+	for _, key := range keyEnvs {
+		if _, found := env[key]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// runKeyCommand runs a key command on the host and returns the key it prints.
+func runKeyCommand(command string) (string, error) {
+	_, out, err := ExecuteCommand(ExecuteOptions{Command: "bash", Silent: true}, "-c", command)
+	if err != nil {
+		return "", fmt.Errorf("key command failed: %s%w", out, err)
+	}
+	key := strings.TrimSpace(out)
+	if key == "" {
+		return "", fmt.Errorf("key command did not print a key")
+	}
+	return key, nil
+}
+
+// validateKey checks that the key file or key command of a backend can provide a key.
+func (b Backend) validateKey(env map[string]string) error {
+	if file, found := env["RESTIC_PASSWORD_FILE"]; found {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("key file of backend %s: %w", b.name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("key file %s of backend %s is a directory", file, b.name)
+		}
+		if info.Size() == 0 {
+			return fmt.Errorf("key file %s of backend %s is empty", file, b.name)
+		}
+	}
+	if command, found := env["RESTIC_PASSWORD_COMMAND"]; found {
+		if _, err := runKeyCommand(command); err != nil {
+			return fmt.Errorf("backend %s: %w", b.name, err)
+		}
+	}
+	return nil
+}
+
+// validateFields checks the config of the backend without accessing the repository.
+func (b Backend) validateFields() error {
 	if b.Type == "" {
 		return fmt.Errorf(`Backend "%s" has no "type"`, b.name)
 	}
 	if b.Path == "" {
 		return fmt.Errorf(`Backend "%s" has no "path"`, b.name)
 	}
-	if b.Key == "" {
-		// Check if key is set in environment
-		env, _ := b.getEnv()
-		if _, found := env["RESTIC_PASSWORD"]; !found {
-			if b.RequireKey {
-				return fmt.Errorf("backend %s requires a key but none was provided", b.name)
-			}
-			// No key set in config file or env => generate random key and save file
-			key := generateRandomKey()
-			b.Key = key
-			c := GetConfig()
-			tmp := c.Backends[b.name]
-			tmp.Key = key
-			c.Backends[b.name] = tmp
-			if err := c.SaveConfig(); err != nil {
-				return err
-			}
+	if b.Jobs < 0 {
+		return fmt.Errorf(`Backend "%s" has an invalid "jobs" value %d`, b.name, b.Jobs)
+	}
+	sources := 0
+	for _, source := range []string{b.Key, b.KeyFile, b.KeyCommand} {
+		if source != "" {
+			sources++
 		}
 	}
+	if sources > 1 {
+		return fmt.Errorf(`Backend "%s" can only have one of "key", "keyFile" and "keyCommand"`, b.name)
+	}
+	return nil
+}
+
+// validate checks the backend and whether its repository can be accessed.
+// It never changes the config or the repository, keys are generated and repositories initialized by Init.
+func (b Backend) validate() error {
+	if err := b.validateFields(); err != nil {
+		return err
+	}
 	env, err := b.getEnv()
 	if err != nil {
 		return err
 	}
+	if !hasKey(env) {
+		if b.RequireKey {
+			return fmt.Errorf("backend %s requires a key but none was provided", b.name)
+		}
+		return fmt.Errorf(`backend %s has no key, run "autorestic init -b %s" to generate one`, b.name, b.name)
+	}
+	if err := b.validateKey(env); err != nil {
+		return err
+	}
 	options := ExecuteOptions{Envs: env, Silent: true}
-	// Check if already initialized
 	cmd := []string{"check"}
 	cmd = append(cmd, combineBackendOptions("check", b)...)
-	_, _, err = ExecuteResticCommand(options, cmd...)
-	if err == nil {
-		return nil
-	} else {
-		// If not initialize
-		colors.Body.Printf("Initializing backend \"%s\"...\n", b.name)
-		cmd := []string{"init"}
-		cmd = append(cmd, combineBackendOptions("init", b)...)
-		_, _, err := ExecuteResticCommand(options, cmd...)
-		return err
+	if _, out, err := ExecuteResticCommand(options, cmd...); err != nil {
+		return fmt.Errorf("backend %s could not be checked, run \"autorestic init -b %s\" if it is not initialized yet:\n%s%w", b.name, b.name, out, err)
+	}
+	return nil
+}
+
+// shellQuote quotes an argument for the shell if needed.
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_=+:,./@%", r))
+	}) == -1 {
+		return arg
 	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
 }
 
 func (b Backend) Exec(args []string) error {
-	env, err := b.getEnv()
+	out, err := b.exec(args)
 	if err != nil {
+		colors.Error.Println(out)
 		return err
 	}
+	return nil
+}
+
+// exec runs restic on the backend and returns its output.
+func (b Backend) exec(args []string) (string, error) {
+	env, err := b.getEnv()
+	if err != nil {
+		return "", err
+	}
 	options := ExecuteOptions{Envs: env}
 	args = append(args, combineBackendOptions("exec", b)...)
 	_, out, err := ExecuteResticCommand(options, args...)
-	if err != nil {
-		colors.Error.Println(out)
-		return err
-	}
-	return nil
+	return out, err
 }
 
 func (b Backend) ExecDocker(l Location, args []string) (int, string, error) {
+	return b.execDocker(l, args, ExecuteOptions{})
+}
+
+// execDocker runs restic in a container with the volume of the location mounted.
+// The output options are passed on to the docker command.
+func (b Backend) execDocker(l Location, args []string, options ExecuteOptions) (int, string, error) {
 	env, err := b.getEnv()
 	if err != nil {
 		return -1, "", err
 	}
 	volume := l.From[0]
-	options := ExecuteOptions{
-		Command: "docker",
-		Envs:    env,
-	}
+	options.Command = "docker"
+	options.Envs = env
 	dir := "/data"
 	args = append([]string{"restic"}, args...)
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
 	docker := []string{
 		"run", "--rm",
 		"--entrypoint", "ash",
@@ -206,8 +301,32 @@ func (b Backend) ExecDocker(l Location, args []string) (int, string, error) {
 	default:
 		return -1, "", fmt.Errorf("Backend type \"%s\" is not supported as volume endpoint", b.Type)
 	}
-	for key, value := range env {
-		docker = append(docker, "--env", key+"="+value)
+	// Key files and commands are only available on the host
+	if file, found := env["RESTIC_PASSWORD_FILE"]; found {
+		// Relative paths would be taken as the name of a volume
+		file, err := filepath.Abs(file)
+		if err != nil {
+			return -1, "", err
+		}
+		docker = append(docker, "--volume", file+":/key:ro")
+		env["RESTIC_PASSWORD_FILE"] = "/key"
+	}
+	if command, found := env["RESTIC_PASSWORD_COMMAND"]; found {
+		key, err := runKeyCommand(command)
+		if err != nil {
+			return -1, "", err
+		}
+		delete(env, "RESTIC_PASSWORD_COMMAND")
+		env["RESTIC_PASSWORD"] = key
+	}
+	// Only the names are passed, docker takes the values from its own environment so that secrets do not show up in the process list
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		docker = append(docker, "--env", key)
 	}
 
 	docker = append(docker, flags.DOCKER_IMAGE, "-c", strings.Join(args, " "))
@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -196,6 +198,40 @@ func TestGetEnv(t *testing.T) {
 		assertEqual(t, result["B2_ACCOUNT_KEY"], "foo456")
 	})
 
+	t.Run("key file", func(t *testing.T) {
+		viper.SetConfigFile("/tmp/.autorestic.yml")
+		defer viper.Reset()
+		b := Backend{
+			name:    "",
+			Type:    "local",
+			Path:    "/foo/bar",
+			KeyFile: "keys/foo",
+		}
+		result, err := b.getEnv()
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		assertEqual(t, result["RESTIC_PASSWORD_FILE"], "/tmp/keys/foo")
+		_, found := result["RESTIC_PASSWORD"]
+		assert.False(t, found)
+	})
+
+	t.Run("key command", func(t *testing.T) {
+		b := Backend{
+			name:       "",
+			Type:       "local",
+			Path:       "/foo/bar",
+			KeyCommand: "pass show backup",
+		}
+		result, err := b.getEnv()
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		assertEqual(t, result["RESTIC_PASSWORD_COMMAND"], "pass show backup")
+		_, found := result["RESTIC_PASSWORD"]
+		assert.False(t, found)
+	})
+
 	for _, char := range "@-_:/" {
 		t.Run(fmt.Sprintf("env var with special char (%c)", char), func(t *testing.T) {
 			// generate env variables
@@ -262,4 +298,98 @@ func TestValidate(t *testing.T) {
 		fmt.Printf("error: %v\n", err)
 		assert.EqualError(t, err, "backend foo requires a key but none was provided")
 	})
+
+	t.Run("require key with key file", func(t *testing.T) {
+		b := Backend{
+			name:       "foo",
+			Type:       "local",
+			Path:       "/foo/bar",
+			KeyFile:    "/does/not/exist",
+			RequireKey: true,
+		}
+		err := b.validate()
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("more than one key source", func(t *testing.T) {
+		b := Backend{
+			name:    "foo",
+			Type:    "local",
+			Path:    "/foo/bar",
+			Key:     "secret123",
+			KeyFile: "/foo/key",
+		}
+		err := b.validate()
+		assert.EqualError(t, err, `Backend "foo" can only have one of "key", "keyFile" and "keyCommand"`)
+	})
+}
+
+func TestValidateKey(t *testing.T) {
+	b := Backend{name: "foo"}
+
+	t.Run("key file", func(t *testing.T) {
+		file := path.Join(t.TempDir(), "key")
+		assert.NoError(t, os.WriteFile(file, []byte("secret123\n"), 0600))
+		assert.NoError(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_FILE": file}))
+	})
+
+	t.Run("empty key file", func(t *testing.T) {
+		file := path.Join(t.TempDir(), "key")
+		assert.NoError(t, os.WriteFile(file, nil, 0600))
+		assert.EqualError(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_FILE": file}), fmt.Sprintf("key file %s of backend foo is empty", file))
+	})
+
+	t.Run("key file is a directory", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.EqualError(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_FILE": dir}), fmt.Sprintf("key file %s of backend foo is a directory", dir))
+	})
+
+	t.Run("key command", func(t *testing.T) {
+		assert.NoError(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_COMMAND": "echo secret123"}))
+	})
+
+	t.Run("failing key command", func(t *testing.T) {
+		assert.Error(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_COMMAND": "exit 1"}))
+	})
+
+	t.Run("key command without output", func(t *testing.T) {
+		assert.EqualError(t, b.validateKey(map[string]string{"RESTIC_PASSWORD_COMMAND": "true"}), "backend foo: key command did not print a key")
+	})
+}
+
+func TestRunKeyCommand(t *testing.T) {
+	key, err := runKeyCommand("printf 'secret123\\n'")
+	assert.NoError(t, err)
+	assertEqual(t, key, "secret123")
+}
+
+func TestShellQuote(t *testing.T) {
+	assertEqual(t, shellQuote("--tag"), "--tag")
+	assertEqual(t, shellQuote("ar:location:foo"), "ar:location:foo")
+	assertEqual(t, shellQuote("/data/my file.txt"), "'/data/my file.txt'")
+	assertEqual(t, shellQuote("*.log"), "'*.log'")
+	assertEqual(t, shellQuote("it's"), `'it'"'"'s'`)
+	assertEqual(t, shellQuote(""), "''")
+}
+
+func TestExecDockerHidesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	docker := "#!/bin/sh\necho \"$@\"\necho \"$RESTIC_PASSWORD $AWS_SECRET_ACCESS_KEY\"\n"
+	assert.NoError(t, os.WriteFile(path.Join(dir, "docker"), []byte(docker), 0755))
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+
+	b := Backend{
+		name:       "foo",
+		Type:       "s3",
+		Path:       "s3.amazonaws.com/bucket",
+		KeyCommand: "echo secret123",
+		Env:        map[string]string{"AWS_SECRET_ACCESS_KEY": "secret456"},
+	}
+	_, out, err := b.execDocker(Location{From: []string{"/data"}}, []string{"snapshots"}, ExecuteOptions{Silent: true})
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "--env AWS_SECRET_ACCESS_KEY --env RESTIC_PASSWORD --env RESTIC_REPOSITORY")
+	assert.NotContains(t, lines[0], "secret")
+	assert.Equal(t, "secret123 secret456", lines[1])
 }
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Node is a file, directory or other entry in a snapshot.
+type Node struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Listing of the contents of a snapshot.
+type Listing struct {
+	Snapshot Snapshot `json:"snapshot"`
+	Nodes    []Node   `json:"nodes"`
+}
+
+// Match of a find in a snapshot.
+type Match struct {
+	Snapshot Snapshot `json:"snapshot"`
+	Node
+}
+
+type resticLsMessage struct {
+	// "struct_type" before restic 0.17, "message_type" since
+	StructType  string `json:"struct_type"`
+	MessageType string `json:"message_type"`
+	Node
+}
+
+// parseLs decodes the output of "restic ls --json".
+func parseLs(out string) ([]Node, error) {
+	nodes := []Node{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var msg resticLsMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return nil, fmt.Errorf("could not parse listing: %w", err)
+		}
+		if msg.StructType == "node" || msg.MessageType == "node" {
+			nodes = append(nodes, msg.Node)
+		}
+	}
+	return nodes, scanner.Err()
+}
+
+type resticFindResult struct {
+	Snapshot string `json:"snapshot"`
+	Matches  []Node `json:"matches"`
+}
+
+// parseFind decodes the output of "restic find --json", returning the matches by snapshot id.
+func parseFind(out string) (map[string][]Node, error) {
+	var results []resticFindResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		return nil, fmt.Errorf("could not parse find results: %w", err)
+	}
+	matches := map[string][]Node{}
+	for _, result := range results {
+		matches[result.Snapshot] = append(matches[result.Snapshot], result.Matches...)
+	}
+	return matches, nil
+}
+
+// resolveSnapshot returns the snapshot of the location with the given id on a backend, or the most recent one if no id is given.
+func (l Location) resolveSnapshot(backend Backend, id string) (Snapshot, error) {
+	snapshots, err := l.getSnapshots(backend)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if id == "" || id == "latest" {
+		if len(snapshots) == 0 {
+			return Snapshot{}, fmt.Errorf("location \"%s\" has no snapshots on backend \"%s\"", l.name, backend.name)
+		}
+		return snapshots[len(snapshots)-1], nil
+	}
+	return l.findSnapshot(backend, snapshots, id)
+}
+
+// Ls lists the contents of a snapshot of the location, or of the most recent one if no id is given.
+// With paths only the contents of these directories are listed.
+func (l Location) Ls(from string, id string, paths []string, recursive bool) (Listing, error) {
+	backend, err := l.selectBackend(from)
+	if err != nil {
+		return Listing{}, err
+	}
+	snapshot, err := l.resolveSnapshot(backend, id)
+	if err != nil {
+		return Listing{}, err
+	}
+
+	env, err := backend.getEnv()
+	if err != nil {
+		return Listing{}, err
+	}
+	cmd := []string{"ls", "--json"}
+	if recursive {
+		cmd = append(cmd, "--recursive")
+	}
+	cmd = append(cmd, combineBackendOptions("ls", backend)...)
+	cmd = append(cmd, snapshot.ID)
+	cmd = append(cmd, paths...)
+	_, out, err := ExecuteResticCommand(ExecuteOptions{Envs: env, Silent: true}, cmd...)
+	if err != nil {
+		return Listing{}, fmt.Errorf("could not list snapshot on backend \"%s\": %s%w", backend.name, out, err)
+	}
+	nodes, err := parseLs(out)
+	return Listing{Snapshot: snapshot, Nodes: nodes}, err
+}
+
+// Find searches the snapshots of the location for files matching the patterns.
+// Without a backend all backends of the location are searched, including copy targets.
+// Backends that fail are reported in the error, the matches of the other backends are still returned.
+func (l Location) Find(from string, patterns []string) ([]Match, error) {
+	backends := l.getBackends()
+	if from != "" {
+		backend, err := l.selectBackend(from)
+		if err != nil {
+			return nil, err
+		}
+		backends = []string{backend.name}
+	}
+
+	var matches []Match
+	var errs []error
+	for _, name := range backends {
+		backend, ok := GetBackend(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid backend \"%s\"", name))
+			continue
+		}
+		m, err := l.findInBackend(backend, patterns)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		matches = append(matches, m...)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].Snapshot.Time.Equal(matches[j].Snapshot.Time) {
+			return matches[i].Snapshot.Time.Before(matches[j].Snapshot.Time)
+		}
+		return matches[i].Path < matches[j].Path
+	})
+	return matches, errors.Join(errs...)
+}
+
+func (l Location) findInBackend(backend Backend, patterns []string) ([]Match, error) {
+	snapshots, err := l.getSnapshots(backend)
+	if err != nil {
+		return nil, err
+	}
+	env, err := backend.getEnv()
+	if err != nil {
+		return nil, err
+	}
+	cmd := []string{"find", "--json", "--tag", l.getLocationTags()}
+	cmd = append(cmd, combineBackendOptions("find", backend)...)
+	cmd = append(cmd, patterns...)
+	_, out, err := ExecuteResticCommand(ExecuteOptions{Envs: env, Silent: true}, cmd...)
+	if err != nil {
+		return nil, fmt.Errorf("could not search backend \"%s\": %s%w", backend.name, out, err)
+	}
+	found, err := parseFind(out)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for id, nodes := range found {
+		snapshot, err := l.findSnapshot(backend, snapshots, id)
+		if err != nil {
+			snapshot = Snapshot{ID: id, ShortID: id[:min(8, len(id))], Location: l.name, Backend: backend.name}
+		}
+		for _, node := range nodes {
+			matches = append(matches, Match{Snapshot: snapshot, Node: node})
+		}
+	}
+	return matches, nil
+}
+
+// Dump writes a single file of a snapshot of the location to w, or the most recent snapshot if no id is given.
+// Directories are written as an archive in the given format, either "tar" (default) or "zip".
+func (l Location) Dump(from string, id string, path string, archive string, w io.Writer) (Snapshot, error) {
+	backend, err := l.selectBackend(from)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot, err := l.resolveSnapshot(backend, id)
+	if err != nil {
+		return snapshot, err
+	}
+	t, err := l.getType()
+	if err != nil {
+		return snapshot, err
+	}
+
+	cmd := []string{"dump"}
+	if archive != "" {
+		cmd = append(cmd, "--archive", archive)
+	}
+	cmd = append(cmd, combineBackendOptions("dump", backend)...)
+	cmd = append(cmd, snapshot.ID, path)
+	var out string
+	switch t {
+	case TypeLocal:
+		var env map[string]string
+		if env, err = backend.getEnv(); err != nil {
+			return snapshot, err
+		}
+		_, out, err = ExecuteResticCommand(ExecuteOptions{Envs: env, Stdout: w}, cmd...)
+	case TypeVolume:
+		_, out, err = backend.execDocker(l, cmd, ExecuteOptions{Stdout: w})
+	}
+	if err != nil {
+		return snapshot, fmt.Errorf("could not dump \"%s\" from backend \"%s\": %s%w", path, backend.name, out, err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLs(t *testing.T) {
+	t.Run("restic before 0.17", func(t *testing.T) {
+		out := `{"time":"2026-10-01T03:00:00Z","paths":["/data"],"hostname":"server","id":"917c7691aaaa","short_id":"917c7691","struct_type":"snapshot"}
+{"name":"data","type":"dir","path":"/data","mtime":"2026-09-30T12:00:00Z","struct_type":"node"}
+{"name":"a.txt","type":"file","path":"/data/a.txt","size":1024,"mtime":"2026-09-30T12:00:00Z","struct_type":"node"}
+`
+		result, err := parseLs(out)
+		assert.NoError(t, err)
+		assert.Len(t, result, 2)
+		assert.Equal(t, "dir", result[0].Type)
+		assert.Equal(t, "/data/a.txt", result[1].Path)
+		assert.Equal(t, int64(1024), result[1].Size)
+	})
+
+	t.Run("restic 0.17", func(t *testing.T) {
+		out := `{"time":"2026-10-01T03:00:00Z","id":"917c7691aaaa","message_type":"snapshot","struct_type":"snapshot"}
+{"name":"a.txt","type":"file","path":"/data/a.txt","size":1024,"message_type":"node","struct_type":"node"}
+`
+		result, err := parseLs(out)
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+	})
+}
+
+func TestParseFind(t *testing.T) {
+	out := `[{"hits":2,"snapshot":"917c7691aaaa","matches":[{"path":"/data/a.conf","type":"file","size":10,"mtime":"2026-09-30T12:00:00Z"},{"path":"/data/b.conf","type":"file","size":20,"mtime":"2026-09-30T12:00:00Z"}]},{"hits":1,"snapshot":"4a3f21c8bbbb","matches":[{"path":"/data/a.conf","type":"file","size":12,"mtime":"2026-10-01T12:00:00Z"}]}]`
+	result, err := parseFind(out)
+	assert.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.Len(t, result["917c7691aaaa"], 2)
+	assert.Equal(t, int64(12), result["4a3f21c8bbbb"][0].Size)
+}
@@ -2,6 +2,7 @@ package colors
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/fatih/color"
@@ -15,13 +16,22 @@ var Error = color.New(color.FgRed, color.Bold)
 var Faint = color.New(color.Faint)
 
 func PrimaryPrint(msg string, args ...interface{}) {
-	fmt.Printf("\n\n%s\n\n", Primary.Sprintf("  "+msg+"  ", args...))
+	PrimaryFprint(color.Output, msg, args...)
+}
+
+func PrimaryFprint(w io.Writer, msg string, args ...interface{}) {
+	fmt.Fprintf(w, "\n\n%s\n\n", Primary.Sprintf("  "+msg+"  ", args...))
 }
 
 func DisableColors(state bool) {
 	color.NoColor = state
 }
 
+// SetOutput redirects all human readable output, e.g. to stderr when printing json.
+func SetOutput(w io.Writer) {
+	color.Output = w
+}
+
 func PrintDescription(left string, right string) {
 	right = strings.Trim(right, "\n")
 	right = strings.Trim(right, "\t")
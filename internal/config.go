@@ -1,16 +1,23 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
 	"github.com/cupcakearmy/autorestic/internal/flags"
+	"github.com/cupcakearmy/autorestic/internal/history"
 	"github.com/cupcakearmy/autorestic/internal/lock"
+	"github.com/cupcakearmy/autorestic/internal/notifications"
+	"github.com/cupcakearmy/autorestic/internal/yamledit"
 	"github.com/joho/godotenv"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -22,12 +29,36 @@ const VERSION = "1.8.3"
 type OptionMap map[string][]interface{}
 type Options map[string]OptionMap
 
+type Metrics struct {
+	Textfile string `mapstructure:"textfile,omitempty" yaml:"textfile,omitempty" json:"textfile,omitempty"`
+}
+
+// Global holds the settings of the "global" section.
+// Every other key in that section is treated as restic options.
+type Global struct {
+	Metrics  Metrics `mapstructure:"metrics,omitempty" yaml:"metrics,omitempty" json:"metrics,omitempty"`
+	Parallel int     `mapstructure:"parallel,omitempty" yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	Options  Options `mapstructure:",remain" yaml:",inline" json:"options,omitempty"`
+}
+
 type Config struct {
-	Version   string              `mapstructure:"version" yaml:"version"`
-	Extras    interface{}         `mapstructure:"extras" yaml:"extras"`
-	Locations map[string]Location `mapstructure:"locations" yaml:"locations"`
-	Backends  map[string]Backend  `mapstructure:"backends" yaml:"backends"`
-	Global    Options             `mapstructure:"global" yaml:"global"`
+	Version   string              `mapstructure:"version" yaml:"version" json:"version"`
+	Include   []string            `mapstructure:"include,omitempty" yaml:"include,omitempty" json:"include,omitempty"`
+	Extras    interface{}         `mapstructure:"extras" yaml:"extras" json:"extras"`
+	Locations map[string]Location `mapstructure:"locations" yaml:"locations" json:"locations"`
+	Backends  map[string]Backend  `mapstructure:"backends" yaml:"backends" json:"backends"`
+	Global    Global              `mapstructure:"global" yaml:"global" json:"global"`
+	Templates Templates           `mapstructure:"templates,omitempty" yaml:"templates,omitempty" json:"templates,omitempty"`
+
+	Notifications map[string]notifications.Config `mapstructure:"notifications,omitempty" yaml:"notifications,omitempty" json:"notifications,omitempty"`
+
+	// Files the locations and backends are defined in, only set if other files are included
+	locationFiles map[string]string
+	backendFiles  map[string]string
+
+	// Locations and backends extending a template, as they are written in the config
+	definedLocations map[string]Location
+	definedBackends  map[string]Backend
 }
 
 var once sync.Once
@@ -40,7 +71,7 @@ func exitConfig(err error, msg string) {
 	if msg != "" {
 		colors.Error.Println(msg)
 	}
-	lock.Unlock()
+	lock.ReleaseAll()
 	os.Exit(1)
 }
 
@@ -54,7 +85,7 @@ func GetConfig() *Config {
 					colors.Faint.Println("Using config: \t", absConfig)
 				}
 				// Load env file
-				envFile := filepath.Join(filepath.Dir(absConfig), ".autorestic.env")
+				envFile := filepath.Join(filepath.Dir(absConfig), ENV_FILE)
 				err = godotenv.Load(envFile)
 				if err == nil && !flags.CRON_LEAN {
 					colors.Faint.Println("Using env:\t", envFile)
@@ -73,30 +104,67 @@ func GetConfig() *Config {
 				os.Exit(1)
 			}
 
-			var versionConfig interface{}
-			viper.UnmarshalKey("version", &versionConfig)
-			if versionConfig == nil {
-				exitConfig(nil, "no version specified in config file. please see docs on how to migrate")
-			}
-			version, ok := versionConfig.(int)
-			if !ok {
-				exitConfig(nil, "version specified in config file is not an int")
-			} else {
-				// Check for version
-				if version != 2 {
-					exitConfig(nil, "unsupported config version number. please check the docs for migration\nhttps://autorestic.vercel.app/migration/")
-				}
-			}
-
-			config = &Config{}
-			if err := viper.UnmarshalExact(config); err != nil {
-				exitConfig(err, "Could not parse config file!")
+			c, err := parseConfig()
+			if err != nil {
+				exitConfig(err, "")
 			}
+			config = c
 		})
 	}
 	return config
 }
 
+// parseConfig parses the config file read by viper, including other files and applying templates.
+func parseConfig() (*Config, error) {
+	var versionConfig interface{}
+	viper.UnmarshalKey("version", &versionConfig)
+	if versionConfig == nil {
+		return nil, errors.New("no version specified in config file. please see docs on how to migrate")
+	}
+	version, ok := versionConfig.(int)
+	if !ok {
+		return nil, errors.New("version specified in config file is not an int")
+	}
+	if version != 2 {
+		return nil, errors.New("unsupported config version number. please check the docs for migration\nhttps://autorestic.vercel.app/migration/")
+	}
+
+	c := &Config{}
+	if err := viper.UnmarshalExact(c); err != nil {
+		return nil, fmt.Errorf("could not parse config file: %w", err)
+	}
+	if err := c.include(); err != nil {
+		return nil, fmt.Errorf("could not include config files: %w", err)
+	}
+	for name, l := range c.Locations {
+		l.name = name
+		c.Locations[name] = l
+	}
+	for name, b := range c.Backends {
+		b.name = name
+		c.Backends[name] = b
+	}
+	if err := c.resolveTemplates(); err != nil {
+		return nil, fmt.Errorf("could not apply templates: %w", err)
+	}
+	return c, nil
+}
+
+// loadConfig reads the config from disk without replacing the current one.
+func loadConfig() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("could not load config file: %w", err)
+	}
+	return parseConfig()
+}
+
+// ReloadConfig drops the cached config and reads it again from disk.
+func ReloadConfig() *Config {
+	config = nil
+	once = sync.Once{}
+	return GetConfig()
+}
+
 func GetPathRelativeToConfig(p string) (string, error) {
 	if path.IsAbs(p) {
 		return p, nil
@@ -108,75 +176,200 @@ func GetPathRelativeToConfig(p string) (string, error) {
 	}
 }
 
-func (c *Config) Describe() {
+const redacted = "***"
+
+// redact returns a copy of the backend without secrets.
+func (b Backend) redact() Backend {
+	if b.Key != "" {
+		b.Key = redacted
+	}
+	if b.KeyCommand != "" {
+		b.KeyCommand = redacted
+	}
+	if b.Rest.Password != "" {
+		b.Rest.Password = redacted
+	}
+	return b
+}
+
+// redact returns a copy of the config without secrets.
+func (c *Config) redact() Config {
+	r := *c
+	r.Backends = make(map[string]Backend, len(c.Backends))
+	for name, b := range c.Backends {
+		r.Backends[name] = b.redact()
+	}
+	r.Templates.Backends = make(map[string]Backend, len(c.Templates.Backends))
+	for name, b := range c.Templates.Backends {
+		r.Templates.Backends[name] = b.redact()
+	}
+	r.Notifications = make(map[string]notifications.Config, len(c.Notifications))
+	for name, n := range c.Notifications {
+		if n.Password != "" {
+			n.Password = redacted
+		}
+		r.Notifications[name] = n
+	}
+	return r
+}
+
+// Describe prints the config.
+// Unless resolved, locations and backends are shown as they are written, together with the templates they extend.
+func (c *Config) Describe(resolved bool) {
+	view := *c
+	if resolved {
+		view.Templates = Templates{}
+	} else {
+		view = c.unresolved()
+	}
+	if events.Enabled() {
+		events.Emit(events.Event{Type: events.Config, Data: view.redact()})
+		return
+	}
+
 	// Locations
-	for name, l := range c.Locations {
-		var tmp string
+	for name, l := range view.Locations {
 		colors.PrimaryPrint(`Location: "%s"`, name)
+		if len(c.Include) > 0 {
+			colors.PrintDescription("File", c.locationFile(name))
+		}
+		describeLocation(l)
+	}
+	for name, l := range view.Templates.Locations {
+		colors.PrimaryPrint(`Location template: "%s"`, name)
+		describeLocation(l)
+	}
+
+	// Backends
+	for name, b := range view.Backends {
+		colors.PrimaryPrint("Backend: \"%s\"", name)
+		if len(c.Include) > 0 {
+			colors.PrintDescription("File", c.backendFile(name))
+		}
+		describeBackend(b)
+	}
+	for name, b := range view.Templates.Backends {
+		colors.PrimaryPrint("Backend template: \"%s\"", name)
+		describeBackend(b)
+	}
+}
 
+func describeLocation(l Location) {
+	if l.Extends != "" {
+		colors.PrintDescription("Extends", l.Extends)
+	}
+
+	var tmp string
+	if len(l.From) > 0 {
 		tmp = ""
 		for _, path := range l.From {
 			tmp += fmt.Sprintf("\t%s %s\n", colors.Success.Sprint("←"), path)
 		}
 		colors.PrintDescription("From", tmp)
+	}
 
+	if len(l.To) > 0 {
 		tmp = ""
 		for _, to := range l.To {
 			tmp += fmt.Sprintf("\t%s %s\n", colors.Success.Sprint("→"), to)
 		}
 		colors.PrintDescription("To", tmp)
+	}
 
-		if l.Cron != "" {
-			colors.PrintDescription("Cron", l.Cron)
-		}
+	if l.Cron != "" {
+		colors.PrintDescription("Cron", l.Cron)
+	}
 
-		tmp = ""
-		hooks := map[string][]string{
-			"PreValidate": l.Hooks.PreValidate,
-			"Before":      l.Hooks.Before,
-			"After":       l.Hooks.After,
-			"Failure":     l.Hooks.Failure,
-			"Success":     l.Hooks.Success,
+	if l.Parallel > 0 {
+		colors.PrintDescription("Parallel", fmt.Sprint(l.Parallel))
+	}
+
+	if l.Verify.isEnabled() {
+		verify := "check"
+		if l.Verify.ReadData {
+			verify += ", read all data"
+		} else if l.Verify.ReadDataSubset != "" {
+			verify += ", read " + l.Verify.ReadDataSubset + " of the data"
 		}
-		for hook, commands := range hooks {
-			if len(commands) > 0 {
-				tmp += "\n\t" + hook
-				for _, cmd := range commands {
-					tmp += colors.Faint.Sprintf("\n\t  ▶ %s", cmd)
-				}
+		colors.PrintDescription("Verify", verify)
+	}
+
+	tmp = ""
+	hooks := map[string][]string{
+		"PreValidate": l.Hooks.PreValidate,
+		"Before":      l.Hooks.Before,
+		"After":       l.Hooks.After,
+		"Failure":     l.Hooks.Failure,
+		"Success":     l.Hooks.Success,
+
+		"Restore Before":  l.Hooks.Restore.Before,
+		"Restore After":   l.Hooks.Restore.After,
+		"Restore Failure": l.Hooks.Restore.Failure,
+		"Restore Success": l.Hooks.Restore.Success,
+	}
+	for hook, commands := range hooks {
+		if len(commands) > 0 {
+			tmp += "\n\t" + hook
+			for _, cmd := range commands {
+				tmp += colors.Faint.Sprintf("\n\t  ▶ %s", cmd)
 			}
 		}
-		if tmp != "" {
-			colors.PrintDescription("Hooks", tmp)
+	}
+	if tmp != "" {
+		colors.PrintDescription("Hooks", tmp)
+	}
+
+	if len(l.Notifications) > 0 {
+		tmp = ""
+		for _, n := range l.Notifications {
+			on := n.On
+			if len(on) == 0 {
+				on = []string{notifications.OnFailure}
+			}
+			tmp += fmt.Sprintf("\n\t%s %s %s", colors.Success.Sprint("✉"), n.Name, colors.Faint.Sprint(strings.Join(on, ", ")))
 		}
+		colors.PrintDescription("Notify", tmp)
+	}
 
-		if len(l.Options) > 0 {
-			tmp = ""
-			for t, options := range l.Options {
-				tmp += "\n\t" + t
-				for option, values := range options {
-					for _, value := range values {
-						tmp += colors.Faint.Sprintf("\n\t  ✧ --%s=%s", option, value)
-					}
+	if len(l.Options) > 0 {
+		tmp = ""
+		for t, options := range l.Options {
+			tmp += "\n\t" + t
+			for option, values := range options {
+				for _, value := range values {
+					tmp += colors.Faint.Sprintf("\n\t  ✧ --%s=%s", option, value)
 				}
 			}
-			colors.PrintDescription("Options", tmp)
 		}
+		colors.PrintDescription("Options", tmp)
 	}
+}
 
-	// Backends
-	for name, b := range c.Backends {
-		colors.PrimaryPrint("Backend: \"%s\"", name)
+func describeBackend(b Backend) {
+	if b.Extends != "" {
+		colors.PrintDescription("Extends", b.Extends)
+	}
+	if b.Type != "" {
 		colors.PrintDescription("Type", b.Type)
+	}
+	if b.Path != "" {
 		colors.PrintDescription("Path", b.Path)
+	}
+	switch {
+	case b.Key != "":
+		colors.PrintDescription("Key", redacted)
+	case b.KeyFile != "":
+		colors.PrintDescription("Key file", b.KeyFile)
+	case b.KeyCommand != "":
+		colors.PrintDescription("Key command", redacted)
+	}
 
-		if len(b.Env) > 0 {
-			tmp := ""
-			for option, value := range b.Env {
-				tmp += fmt.Sprintf("\n\t%s %s %s", colors.Success.Sprint("✧"), strings.ToUpper(option), colors.Faint.Sprint(value))
-			}
-			colors.PrintDescription("Env", tmp)
+	if len(b.Env) > 0 {
+		tmp := ""
+		for option, value := range b.Env {
+			tmp += fmt.Sprintf("\n\t%s %s %s", colors.Success.Sprint("✧"), strings.ToUpper(option), colors.Faint.Sprint(value))
 		}
+		colors.PrintDescription("Env", tmp)
 	}
 }
 
@@ -190,7 +383,19 @@ func CheckConfig() error {
 	}
 	for name, backend := range c.Backends {
 		backend.name = name
-		if err := backend.validate(); err != nil {
+		start := time.Now()
+		err := backend.validate()
+		saveRun(history.Run{
+			Operation: history.OperationCheck,
+			Backend:   name,
+			Start:     start,
+		}, "", err)
+		checked := events.Event{Type: events.BackendChecked, Backend: name, Success: events.Bool(err == nil)}
+		if err != nil {
+			checked.Message = err.Error()
+		}
+		events.Emit(checked)
+		if err != nil {
 			return err
 		}
 	}
@@ -262,17 +467,60 @@ func AddFlagsToCommand(cmd *cobra.Command, backend bool) {
 	}
 }
 
+// SaveConfig writes the backends and locations back to the files they are defined in.
+// Only the values that changed are rewritten, comments and formatting of the files are kept.
 func (c *Config) SaveConfig() error {
-	file := viper.ConfigFileUsed()
-	if err := CopyFile(file, file+".old"); err != nil {
-		return err
+	main := viper.ConfigFileUsed()
+	files := []string{main}
+	backends := map[string]map[string]Backend{main: {}}
+	locations := map[string]map[string]Location{main: {}}
+	add := func(file string) {
+		if _, ok := backends[file]; !ok {
+			files = append(files, file)
+			backends[file] = map[string]Backend{}
+			locations[file] = map[string]Location{}
+		}
 	}
-	colors.Secondary.Println("Saved a backup copy of your file next to the original.")
+	u := c.unresolved()
+	for name, b := range u.Backends {
+		file := c.backendFile(name)
+		add(file)
+		backends[file][name] = b
+	}
+	for name, l := range u.Locations {
+		file := c.locationFile(name)
+		add(file)
+		locations[file][name] = l
+	}
+	sort.Strings(files[1:])
 
-	viper.Set("backends", c.Backends)
-	viper.Set("locations", c.Locations)
+	for _, file := range files {
+		err := updateConfigFile(file, func(doc *yamledit.Document) error {
+			if err := doc.Sync([]string{"backends"}, backends[file]); err != nil {
+				return err
+			}
+			return doc.Sync([]string{"locations"}, locations[file])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return viper.WriteConfig()
+// updateConfigFile applies changes to the config file or one of the included files.
+func updateConfigFile(file string, update func(doc *yamledit.Document) error) error {
+	doc, err := yamledit.Load(file)
+	if errors.Is(err, os.ErrNotExist) && file == viper.ConfigFileUsed() {
+		doc, err = yamledit.Parse([]byte("version: 2\n"))
+	}
+	if err != nil {
+		return err
+	}
+	if err := update(doc); err != nil {
+		return err
+	}
+	return doc.Save(file)
 }
 
 func optionToString(option string) string {
@@ -307,7 +555,7 @@ func getOptions(options Options, keys []string) []string {
 func combineBackendOptions(key string, b Backend) []string {
 	// Priority: backend > global
 	var options []string
-	gFlags := getOptions(GetConfig().Global, []string{key})
+	gFlags := getOptions(GetConfig().Global.Options, []string{key})
 	bFlags := getOptions(b.Options, []string{"all", key})
 	options = append(options, gFlags...)
 	options = append(options, bFlags...)
@@ -317,7 +565,7 @@ func combineBackendOptions(key string, b Backend) []string {
 func combineAllOptions(key string, l Location, b Backend) []string {
 	// Priority: location > backend > global
 	var options []string
-	gFlags := getOptions(GetConfig().Global, []string{key})
+	gFlags := getOptions(GetConfig().Global.Options, []string{key})
 	bFlags := getOptions(b.Options, []string{"all", key})
 	lFlags := getOptions(l.Options, []string{"all", key})
 	options = append(options, gFlags...)
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"os"
 	"path"
 	"reflect"
 	"strconv"
@@ -190,6 +191,27 @@ func TestSaveConfigProducesReadableConfig(t *testing.T) {
 	assert.Equal(t, c, *readConfig)
 }
 
+func TestGlobalOptionsAndSettings(t *testing.T) {
+	workDir := t.TempDir()
+	file := path.Join(workDir, ".autorestic.yml")
+	err := os.WriteFile(file, []byte(`version: 2
+global:
+  metrics:
+    textfile: autorestic.prom
+  all:
+    cache-dir: /tmp/cache
+`), 0644)
+	assert.NoError(t, err)
+	viper.SetConfigFile(file)
+
+	config = nil
+	once = sync.Once{}
+	c := GetConfig()
+	assert.Equal(t, "autorestic.prom", c.Global.Metrics.Textfile)
+	assert.Equal(t, []string{"--cache-dir", "/tmp/cache"}, getOptions(c.Global.Options, []string{"all"}))
+	assert.NotContains(t, c.Global.Options, "metrics")
+}
+
 func assertEqual[T comparable](t testing.TB, result, expected T) {
 	t.Helper()
 
@@ -209,3 +231,42 @@ func assertSliceEqual(t testing.TB, result, expected []string) {
 		assertEqual(t, result[i], expected[i])
 	}
 }
+
+func TestSaveConfigKeepsComments(t *testing.T) {
+	workDir := t.TempDir()
+	file := path.Join(workDir, ".autorestic.yml")
+	err := os.WriteFile(file, []byte(`version: 2 # current
+
+# Where the backups go
+backends:
+  nas: { type: local, path: /mnt/nas }
+
+locations:
+  home:
+    from: /home # everything
+    to: [nas]
+`), 0644)
+	assert.NoError(t, err)
+	viper.Reset()
+	viper.SetConfigFile(file)
+	c := ReloadConfig()
+
+	backend := c.Backends["nas"]
+	backend.Key = "secret"
+	c.Backends["nas"] = backend
+	assert.NoError(t, c.SaveConfig())
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, `version: 2 # current
+
+# Where the backups go
+backends:
+  nas: { type: local, path: /mnt/nas, key: secret }
+
+locations:
+  home:
+    from: /home # everything
+    to: [nas]
+`, string(content))
+}
@@ -3,15 +3,45 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/lock"
+	"github.com/robfig/cron"
+	"github.com/spf13/viper"
 )
 
-func RunCron() error {
+// How often the daemon wakes up at most to check for config changes.
+const daemonPollInterval = time.Minute
+
+// How long the daemon waits before trying a location again that was locked by another instance.
+const lockedRetryInterval = 30 * time.Second
+
+// Locations skipped because they were locked, with the time they are tried again at the earliest.
+var lockedRetries = map[string]time.Time{}
+
+// RunCron backs up all locations that are due, with at most jobs locations at the same time.
+func RunCron(jobs int) error {
 	c := GetConfig()
 	var errs []error
+	var due []BackupJob
 	for name, l := range c.Locations {
 		l.name = name
-		if err := l.RunCron(); err != nil {
+		ok, err := l.claimCron()
+		if err != nil {
 			errs = append(errs, err)
+		} else if ok {
+			due = append(due, BackupJob{Location: l})
+		}
+	}
+	for _, result := range RunBackups(due, jobs, true) {
+		lock.Release(result.Job.Location.LockKeys("")...)
+		if len(result.Errors) > 0 {
+			errs = append(errs, fmt.Errorf("Failed to backup location \"%s\":\n%w", result.Job.Location.name, errors.Join(result.Errors...)))
 		}
 	}
 
@@ -20,3 +50,111 @@ func RunCron() error {
 	}
 	return nil
 }
+
+// RunCronDaemon stays resident and runs cron backups as soon as they are due.
+// The config is reloaded whenever the config file or one of its included files changes on disk.
+func RunCronDaemon(jobs int) error {
+	modified := getConfigModTimes()
+	for {
+		if err := RunCron(jobs); err != nil {
+			colors.Error.Println(err)
+			events.EmitError(err, "", "")
+		}
+
+		next, scheduled, err := nextCronRun(GetConfig())
+		if err != nil {
+			return err
+		}
+		if scheduled {
+			colors.Faint.Printf("Next cron run at %s\n", next.Format(time.RFC1123))
+		} else {
+			colors.Faint.Println("No cron jobs configured, waiting for config changes")
+		}
+
+		for {
+			wait := daemonPollInterval
+			if scheduled && time.Until(next) < wait {
+				wait = time.Until(next)
+			}
+			time.Sleep(wait)
+
+			if m := getConfigModTimes(); !maps.Equal(m, modified) {
+				modified = m
+				colors.Secondary.Println("Config changed, reloading")
+				reloadCronConfig()
+				break
+			}
+			if scheduled && !time.Now().Before(next) {
+				break
+			}
+		}
+	}
+}
+
+// reloadCronConfig reads the changed config. An invalid config is reported and the previous one is kept,
+// so that a typo does not stop the daemon.
+func reloadCronConfig() {
+	c, err := loadConfig()
+	if err == nil {
+		_, _, err = nextCronRun(c)
+	}
+	if err != nil {
+		err = fmt.Errorf("could not reload config, keeping the previous one: %w", err)
+		colors.Error.Println(err)
+		events.EmitError(err, "", "")
+		return
+	}
+	config = c
+}
+
+// nextCronRun returns the earliest time any location is due, based on the last run stored in the lock file.
+// Locations that were locked by another instance are not due before they may be retried.
+func nextCronRun(c *Config) (time.Time, bool, error) {
+	var next time.Time
+	scheduled := false
+	for name, l := range c.Locations {
+		if l.Cron == "" {
+			continue
+		}
+		schedule, err := cron.ParseStandard(l.Cron)
+		if err != nil {
+			return next, false, fmt.Errorf("location \"%s\" has an invalid cron expression: %w", name, err)
+		}
+		due := schedule.Next(time.Unix(lock.GetCron(name), 0))
+		if retry, ok := lockedRetries[name]; ok && due.Before(retry) {
+			due = retry
+		}
+		if !scheduled || due.Before(next) {
+			next = due
+			scheduled = true
+		}
+	}
+	return next, scheduled, nil
+}
+
+// getConfigModTimes returns the modification times of the config file and the files it includes.
+// Every file matching an include pattern is listed, so that added and removed files count as changes too.
+func getConfigModTimes() map[string]time.Time {
+	files := []string{viper.ConfigFileUsed()}
+	for _, pattern := range GetConfig().Include {
+		p, err := GetPathRelativeToConfig(pattern)
+		if err != nil {
+			continue
+		}
+		matches, _ := filepath.Glob(p)
+		if len(matches) == 0 {
+			matches = []string{p}
+		}
+		files = append(files, matches...)
+	}
+
+	times := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		} else {
+			times[file] = time.Time{}
+		}
+	}
+	return times
+}
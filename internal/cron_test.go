@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/lock"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestNextCronRun(t *testing.T) {
+	viper.SetConfigFile(path.Join(t.TempDir(), ".autorestic.yml"))
+	lock.Reset()
+	t.Cleanup(viper.Reset)
+	t.Cleanup(lock.Reset)
+
+	t.Run("no cron", func(t *testing.T) {
+		c := &Config{Locations: map[string]Location{"foo": {}}}
+		_, scheduled, err := nextCronRun(c)
+		assert.NoError(t, err)
+		assert.False(t, scheduled)
+	})
+
+	t.Run("invalid cron", func(t *testing.T) {
+		c := &Config{Locations: map[string]Location{"foo": {Cron: "not a cron"}}}
+		_, _, err := nextCronRun(c)
+		assert.Error(t, err)
+	})
+
+	t.Run("earliest location wins", func(t *testing.T) {
+		c := &Config{Locations: map[string]Location{
+			"foo": {Cron: "0 5 * * *"},
+			"bar": {Cron: "0 3 * * *"},
+		}}
+		next, scheduled, err := nextCronRun(c)
+		assert.NoError(t, err)
+		assert.True(t, scheduled)
+		assert.Equal(t, 3, next.Hour())
+		assert.True(t, next.Before(time.Now()))
+	})
+}
+
+func TestReloadCronConfig(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": "version: 2\nlocations:\n  foo:\n    from: /foo\n    to: nas\n    cron: '0 3 * * *'\n",
+	})
+	t.Cleanup(viper.Reset)
+	file := path.Join(dir, ".autorestic.yml")
+	ReloadConfig()
+
+	for name, content := range map[string]string{
+		"invalid yaml":  "version: 2\nlocations: [\n",
+		"unknown key":   "version: 2\nlocatoins:\n  foo:\n    from: /foo\n",
+		"invalid cron":  "version: 2\nlocations:\n  foo:\n    from: /foo\n    to: nas\n    cron: 'not a cron'\n",
+		"wrong version": "version: 1\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			reloadCronConfig()
+			assert.Equal(t, "0 3 * * *", GetConfig().Locations["foo"].Cron)
+		})
+	}
+
+	assert.NoError(t, os.WriteFile(file, []byte("version: 2\nlocations:\n  foo:\n    from: /foo\n    to: nas\n    cron: '0 4 * * *'\n"), 0644))
+	reloadCronConfig()
+	assert.Equal(t, "0 4 * * *", GetConfig().Locations["foo"].Cron)
+}
+
+func TestCronRetriesLockedLocation(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": "version: 2\nlocations:\n  locked:\n    from: /foo\n    to: nas\n    cron: '* * * * *'\n",
+	})
+	t.Cleanup(viper.Reset)
+	c := ReloadConfig()
+	t.Cleanup(func() { delete(lockedRetries, "locked") })
+
+	// Hold the lock of the location like another instance would
+	assert.NoError(t, os.MkdirAll(path.Join(dir, lock.LOCKS_DIR), 0755))
+	f, err := os.Create(path.Join(dir, lock.LOCKS_DIR, "location-locked.lock"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, unix.Flock(int(f.Fd()), unix.LOCK_EX))
+	hostname, _ := os.Hostname()
+	assert.NoError(t, json.NewEncoder(f).Encode(lock.Holder{Key: lock.LocationKey("locked"), PID: os.Getpid(), Hostname: hostname}))
+
+	l, _ := GetLocation("locked")
+	ok, err := l.claimCron()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The daemon does not try again right away
+	next, scheduled, err := nextCronRun(c)
+	assert.NoError(t, err)
+	assert.True(t, scheduled)
+	assert.WithinDuration(t, time.Now().Add(lockedRetryInterval), next, time.Second)
+
+	// The other instance stores its run before it releases the lock
+	lockFile := path.Join(dir, ".autorestic.lock.yml")
+	assert.NoError(t, os.WriteFile(lockFile, []byte(fmt.Sprintf("cron:\n  locked: %d\n", time.Now().Unix())), 0644))
+	assert.NoError(t, unix.Flock(int(f.Fd()), unix.LOCK_UN))
+	assert.NoError(t, f.Truncate(0))
+	ok, err = l.claimCron()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	holders, err := lock.List()
+	assert.NoError(t, err)
+	assert.Empty(t, holders)
+
+	// Once it is due again the location is claimed
+	assert.NoError(t, os.WriteFile(lockFile, []byte("cron:\n  locked: 0\n"), 0644))
+	ok, err = l.claimCron()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotContains(t, lockedRetries, "locked")
+	lock.Release(l.LockKeys("")...)
+
+	// The run is stored in the lock file next to the config
+	content, err := os.ReadFile(lockFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "locked:")
+	assert.WithinDuration(t, time.Now(), time.Unix(lock.GetCron("locked"), 0), 2*time.Second)
+}
+
+func TestConfigModTimes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": "version: 2\ninclude: [backends.yml, hosts/*.yml]\n",
+		"backends.yml":    "backends: {}\n",
+		"hosts/a.yml":     "locations: {}\n",
+	})
+	t.Cleanup(viper.Reset)
+	ReloadConfig()
+	modified := getConfigModTimes()
+	assert.Len(t, modified, 3)
+
+	// A changed included file
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path.Join(dir, "backends.yml"), later, later))
+	m := getConfigModTimes()
+	assert.NotEqual(t, modified, m)
+	modified = m
+
+	// A new file matching an include pattern
+	assert.NoError(t, os.WriteFile(path.Join(dir, "hosts/b.yml"), []byte("locations: {}\n"), 0644))
+	m = getConfigModTimes()
+	assert.NotEqual(t, modified, m)
+	modified = m
+
+	assert.Equal(t, modified, getConfigModTimes())
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Diff between two snapshots of a location.
+type Diff struct {
+	Backend      string   `json:"backend"`
+	From         Snapshot `json:"from"`
+	To           Snapshot `json:"to"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+	Modified     []string `json:"modified"`
+	AddedBytes   int64    `json:"added_bytes"`
+	RemovedBytes int64    `json:"removed_bytes"`
+}
+
+type resticDiffMessage struct {
+	MessageType string `json:"message_type"`
+	// change
+	Path     string `json:"path"`
+	Modifier string `json:"modifier"`
+	// statistics
+	Added struct {
+		Bytes int64 `json:"bytes"`
+	} `json:"added"`
+	Removed struct {
+		Bytes int64 `json:"bytes"`
+	} `json:"removed"`
+}
+
+// parseDiff decodes the output of "restic diff --json".
+func parseDiff(out string) (Diff, error) {
+	diff := Diff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var msg resticDiffMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return diff, fmt.Errorf("could not parse diff: %w", err)
+		}
+		switch msg.MessageType {
+		case "change":
+			switch msg.Modifier {
+			case "+":
+				diff.Added = append(diff.Added, msg.Path)
+			case "-":
+				diff.Removed = append(diff.Removed, msg.Path)
+			default:
+				diff.Modified = append(diff.Modified, msg.Path)
+			}
+		case "statistics":
+			diff.AddedBytes = msg.Added.Bytes
+			diff.RemovedBytes = msg.Removed.Bytes
+		}
+	}
+	return diff, scanner.Err()
+}
+
+// findSnapshot returns the snapshot of the location on the backend matching a full or short id.
+// An id that is the prefix of more than one snapshot is ambiguous.
+func (l Location) findSnapshot(backend Backend, snapshots []Snapshot, id string) (Snapshot, error) {
+	var matches []Snapshot
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, id) {
+			matches = append(matches, s)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Snapshot{}, fmt.Errorf("snapshot \"%s\" of location \"%s\" not found on backend \"%s\"", id, l.name, backend.name)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, s := range matches {
+		ids[i] = s.ShortID
+	}
+	return Snapshot{}, fmt.Errorf("ambiguous snapshot id \"%s\" of location \"%s\" on backend \"%s\", it matches %s", id, l.name, backend.name, strings.Join(ids, ", "))
+}
+
+// Diff compares two snapshots of the location on a backend.
+// Without ids the two most recent snapshots are compared, with a single id that snapshot is compared to the most recent one.
+func (l Location) Diff(from string, ids []string) (Diff, error) {
+	backend, err := l.selectBackend(from)
+	if err != nil {
+		return Diff{}, err
+	}
+	snapshots, err := l.getSnapshots(backend)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	var pair [2]Snapshot
+	switch len(ids) {
+	case 0:
+		if len(snapshots) < 2 {
+			return Diff{}, fmt.Errorf("location \"%s\" has less than two snapshots on backend \"%s\"", l.name, backend.name)
+		}
+		pair = [2]Snapshot{snapshots[len(snapshots)-2], snapshots[len(snapshots)-1]}
+	case 1:
+		if len(snapshots) == 0 {
+			return Diff{}, fmt.Errorf("location \"%s\" has no snapshots on backend \"%s\"", l.name, backend.name)
+		}
+		pair[1] = snapshots[len(snapshots)-1]
+		ids = []string{ids[0], pair[1].ID}
+		fallthrough
+	default:
+		for i, id := range ids[:2] {
+			s, err := l.findSnapshot(backend, snapshots, id)
+			if err != nil {
+				return Diff{}, err
+			}
+			pair[i] = s
+		}
+	}
+
+	env, err := backend.getEnv()
+	if err != nil {
+		return Diff{}, err
+	}
+	cmd := []string{"diff", "--json", pair[0].ID, pair[1].ID}
+	cmd = append(cmd, combineBackendOptions("diff", backend)...)
+	_, out, err := ExecuteResticCommand(ExecuteOptions{Envs: env, Silent: true}, cmd...)
+	if err != nil {
+		return Diff{}, fmt.Errorf("could not diff snapshots on backend \"%s\": %s%w", backend.name, out, err)
+	}
+	diff, err := parseDiff(out)
+	diff.Backend = backend.name
+	diff.From = pair[0]
+	diff.To = pair[1]
+	return diff, err
+}
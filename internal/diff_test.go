@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiff(t *testing.T) {
+	out := `{"message_type":"change","path":"/data/new.txt","modifier":"+"}
+{"message_type":"change","path":"/data/old.txt","modifier":"-"}
+{"message_type":"change","path":"/data/changed.txt","modifier":"M"}
+{"message_type":"change","path":"/data/link","modifier":"T"}
+{"message_type":"statistics","source_snapshot":"917c7691","target_snapshot":"4a3f21c8","changed_files":1,"added":{"files":1,"dirs":0,"others":0,"data_blobs":1,"tree_blobs":1,"bytes":2048},"removed":{"files":1,"dirs":0,"others":0,"data_blobs":1,"tree_blobs":1,"bytes":512}}
+`
+	result, err := parseDiff(out)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/data/new.txt"}, result.Added)
+	assert.Equal(t, []string{"/data/old.txt"}, result.Removed)
+	assert.Equal(t, []string{"/data/changed.txt", "/data/link"}, result.Modified)
+	assert.Equal(t, int64(2048), result.AddedBytes)
+	assert.Equal(t, int64(512), result.RemovedBytes)
+}
+
+func TestFindSnapshot(t *testing.T) {
+	snapshots := []Snapshot{{ID: "917c7691aaaa", ShortID: "917c7691"}, {ID: "4a3f21c8bbbb", ShortID: "4a3f21c8"}, {ID: "4a3f9999cccc", ShortID: "4a3f9999"}}
+	l := Location{name: "foo"}
+	backend := Backend{name: "nas"}
+
+	t.Run("short id", func(t *testing.T) {
+		result, err := l.findSnapshot(backend, snapshots, "4a3f21c8")
+		assert.NoError(t, err)
+		assert.Equal(t, "4a3f21c8bbbb", result.ID)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := l.findSnapshot(backend, snapshots, "deadbeef")
+		assert.EqualError(t, err, `snapshot "deadbeef" of location "foo" not found on backend "nas"`)
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		_, err := l.findSnapshot(backend, snapshots, "4a3f")
+		assert.EqualError(t, err, `ambiguous snapshot id "4a3f" of location "foo" on backend "nas", it matches 4a3f21c8, 4a3f9999`)
+	})
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/flags"
+)
+
+const (
+	LocationStarted = "location_started"
+	BackendStarted  = "backend_started"
+	HookRan         = "hook_ran"
+	SnapshotSaved   = "snapshot_saved"
+	BackendChecked  = "backend_checked"
+	Config          = "config"
+	Run             = "run"
+	Snapshot        = "snapshot"
+	Diff            = "diff"
+	Listing         = "listing"
+	Match           = "match"
+	RestorePlan     = "restore_plan"
+	Error           = "error"
+	Summary         = "summary"
+)
+
+// Event is printed as a single line of JSON in json output mode.
+type Event struct {
+	Type      string      `json:"type"`
+	Time      time.Time   `json:"time"`
+	Operation string      `json:"operation,omitempty"`
+	Location  string      `json:"location,omitempty"`
+	Backend   string      `json:"backend,omitempty"`
+	Command   string      `json:"command,omitempty"`
+	Success   *bool       `json:"success,omitempty"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+var mutex sync.Mutex
+var errorCount int
+
+func Enabled() bool {
+	return flags.OUTPUT == flags.OutputJSON
+}
+
+// Emit prints the event if json output is enabled, otherwise it does nothing.
+func Emit(e Event) {
+	if e.Type == Error {
+		mutex.Lock()
+		errorCount++
+		mutex.Unlock()
+	}
+	if !Enabled() {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	os.Stdout.Write(append(line, '\n'))
+}
+
+func EmitError(err error, location, backend string) {
+	Emit(Event{Type: Error, Location: location, Backend: backend, Message: err.Error()})
+}
+
+// EmitSummary prints the final object of a command.
+func EmitSummary(command string, start time.Time) {
+	mutex.Lock()
+	errors := errorCount
+	mutex.Unlock()
+	Emit(Event{
+		Type:    Summary,
+		Command: command,
+		Success: Bool(errors == 0),
+		Data: map[string]interface{}{
+			"errors":   errors,
+			"duration": time.Since(start).Seconds(),
+		},
+	})
+}
+
+func Bool(b bool) *bool {
+	return &b
+}
@@ -1,9 +1,19 @@
 package flags
 
+import "time"
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
 var (
-	CI         bool = false
-	VERBOSE    bool = false
-	CRON_LEAN  bool = false
-	RESTIC_BIN string
+	CI           bool = false
+	VERBOSE      bool = false
+	CRON_LEAN    bool = false
+	RESTIC_BIN   string
 	DOCKER_IMAGE string
+	OUTPUT       string = OutputText
+	LOCK_WAIT    bool   = false
+	LOCK_TIMEOUT time.Duration
 )
@@ -0,0 +1,189 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const FILE_NAME = ".autorestic.history.jsonl"
+
+const (
+	OperationBackup = "backup"
+	OperationForget = "forget"
+	OperationCheck  = "check"
+	// Not recorded in the history, only used to describe events
+	OperationRestore = "restore"
+	OperationExec    = "exec"
+	OperationInit    = "init"
+)
+
+// Run is a single operation of a location on one backend.
+// Check runs are not bound to a location.
+type Run struct {
+	Operation       string    `json:"operation"`
+	Location        string    `json:"location,omitempty"`
+	Backend         string    `json:"backend"`
+	Cron            bool      `json:"cron"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	ExitCode        int       `json:"exitCode"`
+	SnapshotID      string    `json:"snapshotId,omitempty"`
+	AddedBytes      int64     `json:"addedBytes"`
+	FilesNew        int64     `json:"filesNew"`
+	FilesChanged    int64     `json:"filesChanged"`
+	FilesUnmodified int64     `json:"filesUnmodified"`
+	Error           string    `json:"error,omitempty"`
+}
+
+func (r Run) Success() bool {
+	return r.Error == "" && r.ExitCode == 0
+}
+
+func (r Run) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// getFile returns the history file, which is stored next to the config and lock file.
+func getFile() (string, error) {
+	p := viper.ConfigFileUsed()
+	if p == "" {
+		return "", fmt.Errorf("cannot use history before reading config location")
+	}
+	return path.Join(path.Dir(p), FILE_NAME), nil
+}
+
+// Append adds a run to the end of the history file.
+func Append(run Run) error {
+	file, err := getFile()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Read returns all runs in the order they were recorded.
+func Read() ([]Run, error) {
+	var r Reader
+	runs, _, err := r.Next()
+	return runs, err
+}
+
+// Reader reads the runs appended to the history since its last call, so that the whole history is only read once.
+type Reader struct {
+	offset int64
+}
+
+// Next returns the runs recorded since the last call.
+// Only complete lines are read and malformed lines are skipped. If the history became shorter in the meantime,
+// it is read from the start again and reset is true.
+func (r *Reader) Next() (runs []Run, reset bool, err error) {
+	file, err := getFile()
+	if err != nil {
+		return nil, false, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			reset = r.offset > 0
+			r.offset = 0
+			return nil, reset, nil
+		}
+		return nil, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() < r.offset {
+		r.offset = 0
+		reset = true
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, r.offset, info.Size()-r.offset))
+	if err != nil {
+		return nil, reset, err
+	}
+	// A line without a newline is still being written
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, reset, nil
+	}
+	r.offset += int64(end + 1)
+
+	for _, line := range bytes.Split(data[:end], []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(line, &run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, reset, nil
+}
+
+// Filter returns the runs of the given locations that started at or after since.
+// An empty list of locations or a zero since disables the respective filter.
+func Filter(runs []Run, locations []string, since time.Time) []Run {
+	var filtered []Run
+	for _, run := range runs {
+		if len(locations) > 0 && !contains(locations, run.Location) {
+			continue
+		}
+		if !since.IsZero() && run.Start.Before(since) {
+			continue
+		}
+		filtered = append(filtered, run)
+	}
+	return filtered
+}
+
+// ParseSince accepts either a duration relative to now (e.g. "36h" or "7d") or a date ("2006-01-02", "2006-01-02 15:04" or RFC 3339).
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time \"%s\"", value)
+}
+
+func contains(list []string, needle string) bool {
+	for _, item := range list {
+		if item == needle {
+			return true
+		}
+	}
+	return false
+}
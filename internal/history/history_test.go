@@ -0,0 +1,126 @@
+package history
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func setup(t *testing.T) {
+	viper.SetConfigFile(path.Join(t.TempDir(), ".autorestic.yml"))
+	t.Cleanup(viper.Reset)
+}
+
+func TestAppendAndRead(t *testing.T) {
+	setup(t)
+
+	runs, err := Read()
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+
+	start := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	first := Run{Location: "foo", Backend: "nas", Start: start, End: start.Add(time.Minute), SnapshotID: "abc", AddedBytes: 42}
+	second := Run{Location: "bar", Backend: "b2", Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), ExitCode: 1, Error: "boom"}
+	assert.NoError(t, Append(first))
+	assert.NoError(t, Append(second))
+
+	runs, err = Read()
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.True(t, runs[0].Start.Equal(first.Start))
+	assert.Equal(t, "abc", runs[0].SnapshotID)
+	assert.True(t, runs[0].Success())
+	assert.Equal(t, time.Minute, runs[0].Duration())
+	assert.False(t, runs[1].Success())
+}
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	runs := []Run{
+		{Location: "foo", Start: start},
+		{Location: "bar", Start: start.Add(time.Hour)},
+		{Location: "foo", Start: start.Add(2 * time.Hour)},
+	}
+
+	assert.Len(t, Filter(runs, nil, time.Time{}), 3)
+	assert.Len(t, Filter(runs, []string{"foo"}, time.Time{}), 2)
+	assert.Len(t, Filter(runs, nil, start.Add(time.Hour)), 2)
+	assert.Len(t, Filter(runs, []string{"foo"}, start.Add(time.Hour)), 1)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 10, 12, 0, 0, 0, time.Local)
+
+	t.Run("duration", func(t *testing.T) {
+		result, err := ParseSince("36h", now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.Add(-36*time.Hour), result)
+	})
+
+	t.Run("days", func(t *testing.T) {
+		result, err := ParseSince("7d", now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.AddDate(0, 0, -7), result)
+	})
+
+	t.Run("date", func(t *testing.T) {
+		result, err := ParseSince("2026-10-01", now)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), result)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseSince("yesterday", now)
+		assert.Error(t, err)
+	})
+}
+
+func TestReader(t *testing.T) {
+	setup(t)
+	file, err := getFile()
+	assert.NoError(t, err)
+
+	var r Reader
+	assert.NoError(t, Append(Run{Location: "foo"}))
+	runs, reset, err := r.Next()
+	assert.NoError(t, err)
+	assert.False(t, reset)
+	assert.Len(t, runs, 1)
+
+	// Only new runs are returned, malformed and incomplete lines are skipped
+	assert.NoError(t, Append(Run{Location: "bar"}))
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("{not json\n{\"location\":\"ba")
+	assert.NoError(t, err)
+	runs, _, err = r.Next()
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "bar", runs[0].Location)
+
+	// The incomplete line is read once it is finished
+	_, err = f.WriteString("z\"}\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	runs, _, err = r.Next()
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "baz", runs[0].Location)
+
+	all, err := Read()
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	// A truncated history is read from the start
+	assert.NoError(t, os.WriteFile(file, nil, 0644))
+	assert.NoError(t, Append(Run{Location: "new"}))
+	runs, reset, err = r.Next()
+	assert.NoError(t, err)
+	assert.True(t, reset)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "new", runs[0].Location)
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// includedConfig holds the sections an included file can define.
+type includedConfig struct {
+	Locations map[string]Location `mapstructure:"locations"`
+	Backends  map[string]Backend  `mapstructure:"backends"`
+	Global    Global              `mapstructure:"global"`
+	Templates Templates           `mapstructure:"templates"`
+}
+
+// getIncludedFiles returns the files matched by the include patterns, in order and without duplicates.
+// Patterns without wildcards must match an existing file.
+func (c *Config) getIncludedFiles() ([]string, error) {
+	main, _ := filepath.Abs(viper.ConfigFileUsed())
+	seen := map[string]bool{main: true}
+	var files []string
+	for _, pattern := range c.Include {
+		p, err := GetPathRelativeToConfig(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern \"%s\": %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[\`) {
+			return nil, fmt.Errorf("included file %s does not exist", p)
+		}
+		for _, match := range matches {
+			abs, _ := filepath.Abs(match)
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+func readIncludedFile(file string) (includedConfig, error) {
+	var included includedConfig
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return included, fmt.Errorf("could not read included file %s: %w", file, err)
+	}
+	if err := v.UnmarshalExact(&included); err != nil {
+		return included, fmt.Errorf("could not parse included file %s: %w", file, err)
+	}
+	return included, nil
+}
+
+// globalKeys returns the settings defined in a global section, options as <command>.<option>.
+func globalKeys(g Global) []string {
+	var keys []string
+	if g.Metrics.Textfile != "" {
+		keys = append(keys, "metrics.textfile")
+	}
+	if g.Parallel != 0 {
+		keys = append(keys, "parallel")
+	}
+	for command, options := range g.Options {
+		for option := range options {
+			keys = append(keys, command+"."+option)
+		}
+	}
+	return keys
+}
+
+func mergeGlobal(dst *Global, src Global) {
+	if src.Metrics.Textfile != "" {
+		dst.Metrics.Textfile = src.Metrics.Textfile
+	}
+	if src.Parallel != 0 {
+		dst.Parallel = src.Parallel
+	}
+	for command, options := range src.Options {
+		if dst.Options == nil {
+			dst.Options = Options{}
+		}
+		if dst.Options[command] == nil {
+			dst.Options[command] = OptionMap{}
+		}
+		for option, values := range options {
+			dst.Options[command][option] = values
+		}
+	}
+}
+
+// include merges the included files into the config and remembers which file every location and backend comes from.
+// A location, backend or global setting may only be defined once across all files.
+func (c *Config) include() error {
+	files, err := c.getIncludedFiles()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	main := viper.ConfigFileUsed()
+	c.locationFiles = map[string]string{}
+	c.backendFiles = map[string]string{}
+	globalFiles := map[string]string{}
+	for name := range c.Locations {
+		c.locationFiles[name] = main
+	}
+	for name := range c.Backends {
+		c.backendFiles[name] = main
+	}
+	for _, key := range globalKeys(c.Global) {
+		globalFiles[key] = main
+	}
+	if c.Locations == nil {
+		c.Locations = map[string]Location{}
+	}
+	if c.Backends == nil {
+		c.Backends = map[string]Backend{}
+	}
+
+	for _, file := range files {
+		included, err := readIncludedFile(file)
+		if err != nil {
+			return err
+		}
+		for name, l := range included.Locations {
+			if other, ok := c.locationFiles[name]; ok {
+				return fmt.Errorf("location \"%s\" is defined in both %s and %s", name, other, file)
+			}
+			c.Locations[name] = l
+			c.locationFiles[name] = file
+		}
+		for name, b := range included.Backends {
+			if other, ok := c.backendFiles[name]; ok {
+				return fmt.Errorf("backend \"%s\" is defined in both %s and %s", name, other, file)
+			}
+			c.Backends[name] = b
+			c.backendFiles[name] = file
+		}
+		for _, key := range globalKeys(included.Global) {
+			if other, ok := globalFiles[key]; ok {
+				return fmt.Errorf("global \"%s\" is defined in both %s and %s", key, other, file)
+			}
+			globalFiles[key] = file
+		}
+		mergeGlobal(&c.Global, included.Global)
+		if err := c.includeTemplates(included.Templates); err != nil {
+			return fmt.Errorf("%w in %s", err, file)
+		}
+	}
+	return nil
+}
+
+// includeTemplates adds the templates of an included file.
+func (c *Config) includeTemplates(templates Templates) error {
+	for name, l := range templates.Locations {
+		if _, ok := c.Templates.Locations[name]; ok {
+			return fmt.Errorf("location template \"%s\" is defined more than once", name)
+		}
+		if c.Templates.Locations == nil {
+			c.Templates.Locations = map[string]Location{}
+		}
+		c.Templates.Locations[name] = l
+	}
+	for name, b := range templates.Backends {
+		if _, ok := c.Templates.Backends[name]; ok {
+			return fmt.Errorf("backend template \"%s\" is defined more than once", name)
+		}
+		if c.Templates.Backends == nil {
+			c.Templates.Backends = map[string]Backend{}
+		}
+		c.Templates.Backends[name] = b
+	}
+	return nil
+}
+
+// locationFile returns the file a location is defined in.
+func (c *Config) locationFile(name string) string {
+	if file, ok := c.locationFiles[name]; ok {
+		return file
+	}
+	return viper.ConfigFileUsed()
+}
+
+// backendFile returns the file a backend is defined in.
+func (c *Config) backendFile(name string) string {
+	if file, ok := c.backendFiles[name]; ok {
+		return file
+	}
+	return viper.ConfigFileUsed()
+}
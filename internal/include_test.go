@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cupcakearmy/autorestic/internal/lock"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		file := path.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(path.Dir(file), 0755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	}
+	viper.Reset()
+	viper.SetConfigFile(path.Join(dir, ".autorestic.yml"))
+	// The lock file is kept next to the config
+	lock.Reset()
+	t.Cleanup(lock.Reset)
+	return dir
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": `version: 2
+include:
+  - backends.yml
+  - hosts/*.yml
+locations:
+  etc:
+    from: /etc
+    to: nas
+global:
+  forget:
+    keep-daily: 7
+`,
+		"backends.yml": `backends:
+  nas:
+    type: local
+    path: /mnt/nas
+global:
+  parallel: 2
+`,
+		"hosts/a.yml": `locations:
+  home:
+    from: /home
+    to: nas
+`,
+		"hosts/b.yml": `global:
+  backup:
+    tag: host-b
+`,
+	})
+
+	c := ReloadConfig()
+	assert.Equal(t, []string{"/etc"}, c.Locations["etc"].From)
+	assert.Equal(t, []string{"/home"}, c.Locations["home"].From)
+	assert.Equal(t, "/mnt/nas", c.Backends["nas"].Path)
+	assert.Equal(t, 2, c.Global.Parallel)
+	assert.Equal(t, []string{"--keep-daily", "7"}, getOptions(c.Global.Options, []string{"forget"}))
+	assert.Equal(t, []string{"--tag", "host-b"}, getOptions(c.Global.Options, []string{"backup"}))
+
+	assert.Equal(t, path.Join(dir, ".autorestic.yml"), c.locationFile("etc"))
+	assert.Equal(t, path.Join(dir, "hosts/a.yml"), c.locationFile("home"))
+	assert.Equal(t, path.Join(dir, "backends.yml"), c.backendFile("nas"))
+}
+
+func TestIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name:     "missing file",
+			include:  "missing.yml",
+			files:    map[string]string{"a.yml": ""},
+			expected: "included file %s/missing.yml does not exist",
+		},
+		{
+			name:     "duplicate location",
+			include:  "*.yml",
+			files:    map[string]string{"a.yml": "locations:\n  home:\n    from: /home\n"},
+			expected: "location \"home\" is defined in both %[1]s/.autorestic.yml and %[1]s/a.yml",
+		},
+		{
+			name:     "duplicate backend",
+			include:  "*.yml",
+			files:    map[string]string{"a.yml": "backends:\n  nas:\n    type: local\n", "b.yml": "backends:\n  nas:\n    type: local\n"},
+			expected: "backend \"nas\" is defined in both %[1]s/a.yml and %[1]s/b.yml",
+		},
+		{
+			name:     "duplicate global",
+			include:  "*.yml",
+			files:    map[string]string{"a.yml": "global:\n  forget:\n    keep-last: 3\n"},
+			expected: "global \"forget.keep-last\" is defined in both %[1]s/.autorestic.yml and %[1]s/a.yml",
+		},
+		{
+			name:     "unknown section",
+			include:  "*.yml",
+			files:    map[string]string{"a.yml": "version: 2\n"},
+			expected: "could not parse included file %s/a.yml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			c := Config{
+				Include:   []string{tt.include},
+				Locations: map[string]Location{"home": {From: []string{"/home"}}},
+				Global:    Global{Options: Options{"forget": {"keep-last": {5}}}},
+			}
+			err := c.include()
+			assert.ErrorContains(t, err, fmt.Sprintf(tt.expected, dir))
+		})
+	}
+}
+
+func TestSaveConfigWritesIncludedFiles(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": "version: 2\ninclude: [backends.yml]\nbackends:\n  a:\n    type: local\n",
+		"backends.yml":    "# shared\nbackends:\n  nas:\n    type: local\n",
+	})
+
+	c := ReloadConfig()
+	nas := c.Backends["nas"]
+	nas.Key = "secret"
+	c.Backends["nas"] = nas
+	assert.NoError(t, c.SaveConfig())
+
+	content, err := os.ReadFile(path.Join(dir, ".autorestic.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2\ninclude: [backends.yml]\nbackends:\n  a:\n    type: local\n", string(content))
+	content, err = os.ReadFile(path.Join(dir, "backends.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# shared\nbackends:\n  nas:\n    type: local\n    key: secret\n", string(content))
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/joho/godotenv"
+)
+
+// KeySink is where a generated key is stored.
+type KeySink string
+
+const (
+	// The key field of the backend in the config
+	KeySinkConfig KeySink = "config"
+	// The .autorestic.env file next to the config
+	KeySinkEnv KeySink = "env"
+	// A key file only readable by the owner, referenced by the keyFile field of the backend
+	KeySinkFile KeySink = "file"
+)
+
+const ENV_FILE = ".autorestic.env"
+
+type InitOptions struct {
+	// Where a generated key is stored, defaults to the config
+	Sink KeySink
+	// Path of the key file for the file sink, relative to the config. Defaults to <backend>.key
+	KeyFile string
+}
+
+// Init initializes the repository of the backend.
+// If the backend has no key yet, a random one is generated and stored in the sink first.
+// Repositories that are already initialized are left untouched.
+func (b Backend) Init(options InitOptions) error {
+	switch options.Sink {
+	case "":
+		options.Sink = KeySinkConfig
+	case KeySinkConfig, KeySinkEnv, KeySinkFile:
+	default:
+		return fmt.Errorf(`invalid key sink "%s", use one of "config", "env" or "file"`, options.Sink)
+	}
+	if err := b.validateFields(); err != nil {
+		return err
+	}
+	env, err := b.getEnv()
+	if err != nil {
+		return err
+	}
+	if !hasKey(env) {
+		if b.RequireKey {
+			return fmt.Errorf("backend %s requires a key but none was provided", b.name)
+		}
+		b, err = b.storeKey(generateRandomKey(), options)
+		if err != nil {
+			return err
+		}
+		if env, err = b.getEnv(); err != nil {
+			return err
+		}
+	}
+	if err := b.validateKey(env); err != nil {
+		return err
+	}
+
+	executeOptions := ExecuteOptions{Envs: env, Silent: true}
+	if _, _, err := ExecuteResticCommand(executeOptions, "cat", "config"); err == nil {
+		colors.Body.Printf("Backend \"%s\" is already initialized\n", b.name)
+		return nil
+	}
+	colors.Body.Printf("Initializing backend \"%s\"...\n", b.name)
+	cmd := []string{"init"}
+	cmd = append(cmd, combineBackendOptions("init", b)...)
+	if _, out, err := ExecuteResticCommand(executeOptions, cmd...); err != nil {
+		return fmt.Errorf("%s%w", out, err)
+	}
+	return nil
+}
+
+// storeKey saves a generated key in the sink and returns the backend using it.
+func (b Backend) storeKey(key string, options InitOptions) (Backend, error) {
+	switch options.Sink {
+	case KeySinkConfig:
+		b.Key = key
+		if err := b.save(); err != nil {
+			return b, err
+		}
+		colors.Secondary.Printf("Saved the key of backend \"%s\" in the config\n", b.name)
+	case KeySinkEnv:
+		file, err := GetPathRelativeToConfig(ENV_FILE)
+		if err != nil {
+			return b, err
+		}
+		variable := b.getEnvPrefix() + "RESTIC_PASSWORD"
+		if err := appendEnvFile(file, variable, key); err != nil {
+			return b, err
+		}
+		// The env file has already been loaded
+		os.Setenv(variable, key)
+		colors.Secondary.Printf("Saved the key of backend \"%s\" in %s\n", b.name, file)
+	case KeySinkFile:
+		keyFile := options.KeyFile
+		if keyFile == "" {
+			keyFile = b.name + ".key"
+		}
+		file, err := GetPathRelativeToConfig(keyFile)
+		if err != nil {
+			return b, err
+		}
+		if err := writeKeyFile(file, key); err != nil {
+			return b, err
+		}
+		colors.Secondary.Printf("Saved the key of backend \"%s\" in %s\n", b.name, file)
+		b.KeyFile = keyFile
+		if err := b.save(); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// save writes the changed backend back to the file it is defined in.
+func (b Backend) save() error {
+	c := GetConfig()
+	c.Backends[b.name] = b
+	return c.SaveConfig()
+}
+
+// appendEnvFile adds a variable to an env file, creating it if needed. Variables that are already set are never overwritten.
+func appendEnvFile(file string, variable string, value string) error {
+	content, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	existing, err := godotenv.Unmarshal(string(content))
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", file, err)
+	}
+	if _, ok := existing[variable]; ok {
+		return fmt.Errorf("%s is already set in %s", variable, file)
+	}
+	line := fmt.Sprintf("%s=%s\n", variable, value)
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		line = "\n" + line
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line)
+	return err
+}
+
+// writeKeyFile creates a key file only readable by the owner. Existing files are never overwritten.
+func writeKeyFile(file string, key string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(key + "\n")
+	return err
+}
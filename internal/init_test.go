@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitInvalidSink(t *testing.T) {
+	b := Backend{name: "foo", Type: "local", Path: "/foo/bar"}
+	err := b.Init(InitOptions{Sink: "vault"})
+	assert.EqualError(t, err, `invalid key sink "vault", use one of "config", "env" or "file"`)
+}
+
+func TestValidateWithoutKey(t *testing.T) {
+	b := Backend{name: "foo", Type: "local", Path: "/foo/bar"}
+	err := b.validate()
+	assert.EqualError(t, err, `backend foo has no key, run "autorestic init -b foo" to generate one`)
+}
+
+func TestAppendEnvFile(t *testing.T) {
+	t.Run("new file", func(t *testing.T) {
+		file := path.Join(t.TempDir(), ENV_FILE)
+		assert.NoError(t, appendEnvFile(file, "AUTORESTIC_FOO_RESTIC_PASSWORD", "secret123"))
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "AUTORESTIC_FOO_RESTIC_PASSWORD=secret123\n", string(content))
+		info, err := os.Stat(file)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("existing file without trailing newline", func(t *testing.T) {
+		file := path.Join(t.TempDir(), ENV_FILE)
+		assert.NoError(t, os.WriteFile(file, []byte("AUTORESTIC_BAR_B2_ACCOUNT_ID=foo123"), 0600))
+		assert.NoError(t, appendEnvFile(file, "AUTORESTIC_FOO_RESTIC_PASSWORD", "secret123"))
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "AUTORESTIC_BAR_B2_ACCOUNT_ID=foo123\nAUTORESTIC_FOO_RESTIC_PASSWORD=secret123\n", string(content))
+	})
+
+	t.Run("variable already set", func(t *testing.T) {
+		file := path.Join(t.TempDir(), ENV_FILE)
+		assert.NoError(t, os.WriteFile(file, []byte("# backend foo\nexport AUTORESTIC_FOO_RESTIC_PASSWORD=old\n"), 0600))
+		err := appendEnvFile(file, "AUTORESTIC_FOO_RESTIC_PASSWORD", "secret123")
+		assert.EqualError(t, err, "AUTORESTIC_FOO_RESTIC_PASSWORD is already set in "+file)
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "# backend foo\nexport AUTORESTIC_FOO_RESTIC_PASSWORD=old\n", string(content))
+	})
+}
+
+func TestWriteKeyFile(t *testing.T) {
+	file := path.Join(t.TempDir(), "keys", "foo.key")
+	assert.NoError(t, writeKeyFile(file, "secret123"))
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret123\n", string(content))
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Existing keys are never overwritten
+	assert.ErrorIs(t, writeKeyFile(file, "other"), os.ErrExist)
+}
+
+func TestStoreKeyInConfig(t *testing.T) {
+	config := `version: 2
+templates:
+  backends:
+    local:
+      type: local
+backends:
+  a: &base
+    type: local
+    path: a # shared settings
+  b:
+    <<: *base
+    path: b
+  c:
+    extends: local
+    type: local # written explicitly
+    path: c
+`
+	dir := writeConfigFiles(t, map[string]string{".autorestic.yml": config})
+	t.Cleanup(viper.Reset)
+	ReloadConfig()
+
+	store := func(name string) {
+		t.Helper()
+		b, _ := GetBackend(name)
+		_, err := b.storeKey("key-"+name, InitOptions{Sink: KeySinkConfig})
+		assert.NoError(t, err)
+	}
+	store("a")
+	store("c")
+
+	content, err := os.ReadFile(path.Join(dir, ".autorestic.yml"))
+	assert.NoError(t, err)
+	// The backend merging a does not inherit its key, c keeps its values and gets none of the template
+	assert.Equal(t, `version: 2
+templates:
+  backends:
+    local:
+      type: local
+backends:
+  a: &base
+    type: local
+    path: a # shared settings
+    key: key-a
+  b:
+    <<: *base
+    path: b
+    key: null
+  c:
+    extends: local
+    type: local # written explicitly
+    path: c
+    key: key-c
+`, string(content))
+
+	store("b")
+	content, err = os.ReadFile(path.Join(dir, ".autorestic.yml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "  b:\n    <<: *base\n    path: b\n    key: key-b\n")
+	assert.Equal(t, "key-b", ReloadConfig().Backends["b"].Key)
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// BackupJob is the backup of a location, optionally only to a specific backend.
+type BackupJob struct {
+	Location Location
+	Backend  string
+}
+
+type BackupResult struct {
+	Job    BackupJob
+	Errors []error
+}
+
+// RunBackups backs up the locations with at most jobs locations at the same time.
+// The results keep the order of the given jobs.
+func RunBackups(backupJobs []BackupJob, jobs int, cron bool) []BackupResult {
+	results := make([]BackupResult, len(backupJobs))
+	runConcurrently(len(backupJobs), jobs, color.Output, func(i int, output io.Writer) {
+		job := backupJobs[i]
+		job.Location.output = output
+		errs := job.Location.Backup(cron, job.Backend)
+		job.Location.output = nil
+		results[i] = BackupResult{Job: job, Errors: errs}
+	})
+	return results
+}
+
+// runConcurrently calls fn for every index from 0 to count with at most n calls running at the same time.
+// When running concurrently every call writes to its own buffer, which is copied to output once the call is done.
+// This way the outputs of the calls do not interleave.
+func runConcurrently(count int, n int, output io.Writer, fn func(i int, output io.Writer)) {
+	if n <= 1 || count <= 1 {
+		for i := 0; i < count; i++ {
+			fn(i, output)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	var outputMutex sync.Mutex
+	slots := make(chan struct{}, n)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var buffer bytes.Buffer
+			fn(i, &buffer)
+			outputMutex.Lock()
+			io.Copy(output, &buffer)
+			outputMutex.Unlock()
+		}(i)
+	}
+	wg.Wait()
+}
+
+var backendSlots = make(map[string]chan struct{})
+var backendSlotsMutex sync.Mutex
+
+// acquireBackend blocks until the backend has a free slot and returns a function to release it again.
+// By default only one location at a time can use a backend.
+func acquireBackend(b Backend) func() {
+	backendSlotsMutex.Lock()
+	slots, ok := backendSlots[b.name]
+	if !ok {
+		limit := b.Jobs
+		if limit < 1 {
+			limit = 1
+		}
+		slots = make(chan struct{}, limit)
+		backendSlots[b.name] = slots
+	}
+	backendSlotsMutex.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// acquireBackends takes a slot of every backend, always in the same order so that two jobs cannot wait for each other.
+func acquireBackends(backends ...Backend) func() {
+	sorted := append([]Backend{}, backends...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	var releases []func()
+	for i, b := range sorted {
+		if i > 0 && b.name == sorted[i-1].name {
+			continue
+		}
+		releases = append(releases, acquireBackend(b))
+	}
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
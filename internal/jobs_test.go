@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/flags"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	t.Run("respects limit", func(t *testing.T) {
+		var running, max int32
+		runConcurrently(6, 2, io.Discard, func(i int, output io.Writer) {
+			current := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if current <= m || atomic.CompareAndSwapInt32(&max, m, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+		assertEqual(t, max, int32(2))
+	})
+
+	t.Run("output does not interleave", func(t *testing.T) {
+		var output strings.Builder
+		runConcurrently(3, 3, &output, func(i int, output io.Writer) {
+			for j := 0; j < 3; j++ {
+				fmt.Fprintf(output, "%d\n", i)
+				time.Sleep(time.Millisecond)
+			}
+		})
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		assert.Len(t, lines, 9)
+		for i := 0; i < len(lines); i += 3 {
+			assert.Equal(t, lines[i], lines[i+1])
+			assert.Equal(t, lines[i], lines[i+2])
+		}
+	})
+
+	t.Run("sequential", func(t *testing.T) {
+		var output strings.Builder
+		runConcurrently(3, 1, &output, func(i int, output io.Writer) {
+			fmt.Fprint(output, i)
+		})
+		assertEqual(t, output.String(), "012")
+	})
+}
+
+func TestAcquireBackend(t *testing.T) {
+	b := Backend{name: "acquire-test", Jobs: 2}
+	var wg sync.WaitGroup
+	var running, max int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := acquireBackend(b)
+			defer release()
+			current := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if current <= m || atomic.CompareAndSwapInt32(&max, m, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+	assertEqual(t, max, int32(2))
+}
+
+func TestAcquireBackends(t *testing.T) {
+	a := Backend{name: "acquire-test-a"}
+	b := Backend{name: "acquire-test-b"}
+	release := acquireBackend(b)
+
+	acquired := make(chan func())
+	go func() { acquired <- acquireBackends(b, a, a) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a backend that is in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case releaseBoth := <-acquired:
+		releaseBoth()
+	case <-time.After(time.Second):
+		t.Fatal("backends were not acquired after they were released")
+	}
+	acquireBackends(a, b)()
+}
+
+// fakeRestic backs up every repository by waiting for all repositories listed in $WAIT_FOR to have started,
+// so that it only succeeds if they run at the same time. Repositories named "fail-*" fail.
+// Every call is logged with its repositories to $MARKERS/calls.
+const fakeRestic = `#!/bin/bash
+repo=$(basename "$RESTIC_REPOSITORY")
+echo "$1 $repo${RESTIC_REPOSITORY2:+ $(basename "$RESTIC_REPOSITORY2")}" >> "$MARKERS/calls"
+[ "$1" = "backup" ] || exit 0
+touch "$MARKERS/$repo"
+for other in $WAIT_FOR; do
+  for i in $(seq 100); do [ -e "$MARKERS/$other" ] && break; sleep 0.05; done
+  [ -e "$MARKERS/$other" ] || { echo "$other did not start" >&2; exit 1; }
+done
+case "$repo" in
+  fail-*) echo "$repo is broken" >&2; exit 1;;
+  slow) sleep 0.5;;
+esac
+echo '{"message_type":"summary","snapshot_id":"'$repo'"}'
+`
+
+func setupBackups(t *testing.T, location string) (string, Location) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": `version: 2
+backends:
+  slow: {type: local, path: slow, key: secret}
+  fast: {type: local, path: fast, key: secret}
+  fail-a: {type: local, path: fail-a, key: secret}
+  fail-b: {type: local, path: fail-b, key: secret}
+locations:
+` + location,
+		"data/file": "data",
+		"restic":    fakeRestic,
+	})
+	t.Cleanup(viper.Reset)
+	assert.NoError(t, os.Chmod(path.Join(dir, "restic"), 0755))
+	bin := flags.RESTIC_BIN
+	flags.RESTIC_BIN = path.Join(dir, "restic")
+	t.Cleanup(func() { flags.RESTIC_BIN = bin })
+	markers := path.Join(dir, "markers")
+	assert.NoError(t, os.Mkdir(markers, 0755))
+	t.Setenv("MARKERS", markers)
+
+	ReloadConfig()
+	l, ok := GetLocation("foo")
+	assert.True(t, ok)
+	l.output = io.Discard
+	return dir, l
+}
+
+func TestBackupToBackends(t *testing.T) {
+	t.Run("backends run concurrently", func(t *testing.T) {
+		_, l := setupBackups(t, "  foo: {from: data, to: [slow, fast], parallel: 2}\n")
+		t.Setenv("WAIT_FOR", "slow fast")
+		assert.Empty(t, l.Backup(false, ""))
+	})
+
+	t.Run("errors of all backends are collected", func(t *testing.T) {
+		_, l := setupBackups(t, "  foo: {from: data, to: [fail-a, fast, fail-b], parallel: 3}\n")
+		errs := l.Backup(false, "")
+		assert.Len(t, errs, 2)
+		message := errors.Join(errs...).Error()
+		assert.Contains(t, message, "fail-a is broken")
+		assert.Contains(t, message, "fail-b is broken")
+	})
+
+	t.Run("a single backend is copied and forgotten alone", func(t *testing.T) {
+		dir, l := setupBackups(t, "  foo: {from: data, to: [slow, fast], forget: prune, copy: {slow: [fail-a], fast: [fail-b]}}\n")
+		assert.Empty(t, l.Backup(false, "fast"))
+		calls, err := os.ReadFile(path.Join(dir, "markers", "calls"))
+		assert.NoError(t, err)
+		assert.Equal(t, "backup fast\ncat fast\ncopy fast fail-b\nforget fast\nforget fail-b\n", string(calls))
+	})
+
+	t.Run("failures before the backup are notified", func(t *testing.T) {
+		var notified []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			notified = append(notified, string(body))
+		}))
+		defer server.Close()
+		_, l := setupBackups(t, `  foo: {from: data, to: fast, notifications: [{name: hook}]}
+  broken: {from: data, to: fast, type: unknown, notifications: [{name: hook}]}
+notifications:
+  hook: {type: webhook, url: `+server.URL+`, body: '{{ .Location }} {{ .Status }}'}
+`)
+		assert.Len(t, l.Backup(false, "missing"), 1)
+		broken, _ := GetLocation("broken")
+		broken.output = io.Discard
+		assert.Len(t, broken.Backup(false, ""), 1)
+		assert.Equal(t, []string{"foo failure", "broken failure"}, notified)
+	})
+
+	t.Run("env numbering follows the order of the backends", func(t *testing.T) {
+		dir, l := setupBackups(t, `  foo:
+    from: data
+    to: [slow, fast]
+    parallel: 2
+    hooks:
+      after:
+        - echo "$AUTORESTIC_SNAPSHOT_ID_0 $AUTORESTIC_SNAPSHOT_ID_1 $AUTORESTIC_SNAPSHOT_ID_FAST" > env
+`)
+		// The slow backend finishes last, but keeps its number
+		assert.Empty(t, l.Backup(false, ""))
+		content, err := os.ReadFile(path.Join(dir, "env"))
+		assert.NoError(t, err)
+		assert.Equal(t, "slow fast fast\n", string(content))
+	})
+}
@@ -3,16 +3,19 @@ package internal
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
 	"github.com/cupcakearmy/autorestic/internal/flags"
+	"github.com/cupcakearmy/autorestic/internal/history"
 	"github.com/cupcakearmy/autorestic/internal/lock"
 	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/cupcakearmy/autorestic/internal/notifications"
+	"github.com/fatih/color"
 	"github.com/robfig/cron"
 )
 
@@ -34,26 +37,64 @@ const (
 )
 
 type Hooks struct {
-	Dir         string    `mapstructure:"dir" yaml:"dir"`
-	PreValidate HookArray `mapstructure:"prevalidate,omitempty" yaml:"prevalidate,omitempty"`
-	Before      HookArray `mapstructure:"before,omitempty" yaml:"before,omitempty"`
-	After       HookArray `mapstructure:"after,omitempty" yaml:"after,omitempty"`
-	Success     HookArray `mapstructure:"success,omitempty" yaml:"success,omitempty"`
-	Failure     HookArray `mapstructure:"failure,omitempty" yaml:"failure,omitempty"`
+	Dir         string    `mapstructure:"dir" yaml:"dir" json:"dir"`
+	PreValidate HookArray `mapstructure:"prevalidate,omitempty" yaml:"prevalidate,omitempty" json:"prevalidate,omitempty"`
+	Before      HookArray `mapstructure:"before,omitempty" yaml:"before,omitempty" json:"before,omitempty"`
+	After       HookArray `mapstructure:"after,omitempty" yaml:"after,omitempty" json:"after,omitempty"`
+	Success     HookArray `mapstructure:"success,omitempty" yaml:"success,omitempty" json:"success,omitempty"`
+	Failure     HookArray `mapstructure:"failure,omitempty" yaml:"failure,omitempty" json:"failure,omitempty"`
+
+	Restore RestoreHooks `mapstructure:"restore,omitempty" yaml:"restore,omitempty" json:"restore,omitempty"`
+}
+
+// RestoreHooks are run around a restore of the location.
+type RestoreHooks struct {
+	Before  HookArray `mapstructure:"before,omitempty" yaml:"before,omitempty" json:"before,omitempty"`
+	After   HookArray `mapstructure:"after,omitempty" yaml:"after,omitempty" json:"after,omitempty"`
+	Success HookArray `mapstructure:"success,omitempty" yaml:"success,omitempty" json:"success,omitempty"`
+	Failure HookArray `mapstructure:"failure,omitempty" yaml:"failure,omitempty" json:"failure,omitempty"`
+}
+
+func (h RestoreHooks) isEmpty() bool {
+	return len(h.Before) == 0 && len(h.After) == 0 && len(h.Success) == 0 && len(h.Failure) == 0
 }
 
 type LocationCopy = map[string][]string
 
+type LocationNotification struct {
+	Name string   `mapstructure:"name" yaml:"name" json:"name"`
+	On   []string `mapstructure:"on,omitempty" yaml:"on,omitempty" json:"on,omitempty"`
+}
+
 type Location struct {
-	name         string               `mapstructure:",omitempty" yaml:",omitempty"`
-	From         []string             `mapstructure:"from,omitempty" yaml:"from,omitempty"`
-	Type         string               `mapstructure:"type,omitempty" yaml:"type,omitempty"`
-	To           []string             `mapstructure:"to,omitempty" yaml:"to,omitempty"`
-	Hooks        Hooks                `mapstructure:"hooks,omitempty" yaml:"hooks,omitempty"`
-	Cron         string               `mapstructure:"cron,omitempty" yaml:"cron,omitempty"`
-	Options      Options              `mapstructure:"options,omitempty" yaml:"options,omitempty"`
-	ForgetOption LocationForgetOption `mapstructure:"forget,omitempty" yaml:"forget,omitempty"`
-	CopyOption   LocationCopy         `mapstructure:"copy,omitempty" yaml:"copy,omitempty"`
+	name         string `mapstructure:",omitempty" yaml:",omitempty"`
+	output       io.Writer
+	Extends      string               `mapstructure:"extends,omitempty" yaml:"extends,omitempty" json:"extends,omitempty"`
+	From         []string             `mapstructure:"from,omitempty" yaml:"from,omitempty" json:"from,omitempty"`
+	Type         string               `mapstructure:"type,omitempty" yaml:"type,omitempty" json:"type,omitempty"`
+	To           []string             `mapstructure:"to,omitempty" yaml:"to,omitempty" json:"to,omitempty"`
+	Hooks        Hooks                `mapstructure:"hooks,omitempty" yaml:"hooks,omitempty" json:"hooks,omitempty"`
+	Cron         string               `mapstructure:"cron,omitempty" yaml:"cron,omitempty" json:"cron,omitempty"`
+	Options      Options              `mapstructure:"options,omitempty" yaml:"options,omitempty" json:"options,omitempty"`
+	ForgetOption LocationForgetOption `mapstructure:"forget,omitempty" yaml:"forget,omitempty" json:"forget,omitempty"`
+	CopyOption   LocationCopy         `mapstructure:"copy,omitempty" yaml:"copy,omitempty" json:"copy,omitempty"`
+	Parallel     int                  `mapstructure:"parallel,omitempty" yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	Verify       LocationVerify       `mapstructure:"verify,omitempty" yaml:"verify,omitempty" json:"verify,omitempty"`
+
+	Notifications []LocationNotification `mapstructure:"notifications,omitempty" yaml:"notifications,omitempty" json:"notifications,omitempty"`
+}
+
+func (l Location) Name() string {
+	return l.name
+}
+
+// out returns where the output of the location is written to.
+// When running locations concurrently it is buffered, otherwise it is the output of the colors.
+func (l Location) out() io.Writer {
+	if l.output != nil {
+		return l.output
+	}
+	return color.Output
 }
 
 func GetLocation(name string) (Location, bool) {
@@ -116,6 +157,28 @@ func (l Location) validate() error {
 		}
 	}
 
+	// Check notifications
+	for _, n := range l.Notifications {
+		c, ok := GetConfig().Notifications[n.Name]
+		if !ok {
+			return fmt.Errorf(`location "%s" has an invalid notification "%s"`, l.name, n.Name)
+		}
+		if _, err := notifications.New(n.Name, c); err != nil {
+			return err
+		}
+		if err := notifications.ValidateOn(n.On); err != nil {
+			return fmt.Errorf(`location "%s": %w`, l.name, err)
+		}
+	}
+
+	if l.Parallel < 0 {
+		return fmt.Errorf(`location "%s" has an invalid "parallel" value %d`, l.name, l.Parallel)
+	}
+
+	if err := l.Verify.validate(); err != nil {
+		return fmt.Errorf(`location "%s": %w`, l.name, err)
+	}
+
 	// Check if forget type is correct
 	if l.ForgetOption != "" {
 		if l.ForgetOption != LocationForgetYes && l.ForgetOption != LocationForgetNo && l.ForgetOption != LocationForgetPrune {
@@ -136,16 +199,23 @@ func (l Location) ExecuteHooks(commands []string, options ExecuteOptions) error
 			options.Dir = dir
 		}
 	}
-	colors.Secondary.Println("\nRunning hooks")
+	options.Output = l.out()
+	colors.Secondary.Fprintln(l.out(), "\nRunning hooks")
 	for _, command := range commands {
-		colors.Body.Println("> " + command)
+		colors.Body.Fprintln(l.out(), "> "+command)
 		_, out, err := ExecuteCommand(options, "-c", command)
+		events.Emit(events.Event{
+			Type:     events.HookRan,
+			Location: l.name,
+			Command:  command,
+			Success:  events.Bool(err == nil),
+		})
 		if err != nil {
-			colors.Error.Println(out)
+			colors.Error.Fprintln(l.out(), out)
 			return err
 		}
 	}
-	colors.Body.Println("")
+	colors.Body.Fprintln(l.out(), "")
 	return nil
 }
 
@@ -171,12 +241,11 @@ func (l Location) getLocationTags() string {
 func (l Location) Backup(cron bool, specificBackend string) []error {
 	var errors []error
 	var backends []string
-	colors.PrimaryPrint("  Backing up location \"%s\"  ", l.name)
-	t, err := l.getType()
-	if err != nil {
-		errors = append(errors, err)
-		return errors
-	}
+	var paths []string
+	var runs []history.Run
+	start := time.Now()
+	colors.PrimaryFprint(l.out(), "  Backing up location \"%s\"  ", l.name)
+	events.Emit(events.Event{Type: events.LocationStarted, Operation: history.OperationBackup, Location: l.name})
 	cwd, _ := GetPathRelativeToConfig(".")
 	options := ExecuteOptions{
 		Command: "bash",
@@ -185,6 +254,11 @@ func (l Location) Backup(cron bool, specificBackend string) []error {
 			"AUTORESTIC_LOCATION": l.name,
 		},
 	}
+	t, err := l.getType()
+	if err != nil {
+		errors = append(errors, err)
+		goto after
+	}
 
 	// Hooks before location validation
 	if err := l.ExecuteHooks(l.Hooks.PreValidate, options); err != nil {
@@ -211,89 +285,23 @@ func (l Location) Backup(cron bool, specificBackend string) []error {
 			backends = []string{specificBackend}
 		} else {
 			errors = append(errors, fmt.Errorf("backup location \"%s\" has no backend \"%s\"", l.name, specificBackend))
-			return errors
+			goto after
 		}
 	}
-	for i, to := range backends {
-		backend, _ := GetBackend(to)
-		colors.Secondary.Printf("Backend: %s\n", backend.name)
-		env, err := backend.getEnv()
+	if t == TypeLocal {
+		paths, err = l.getPaths()
 		if err != nil {
 			errors = append(errors, err)
-			continue
-		}
-
-		cmd := []string{"backup"}
-		cmd = append(cmd, combineAllOptions("backup", l, backend)...)
-		if cron {
-			cmd = append(cmd, "--tag", buildTag("cron"))
+			goto after
 		}
-		cmd = append(cmd, "--tag", l.getLocationTags())
-		backupOptions := ExecuteOptions{
-			Envs: env,
-		}
-
-		var code int = 0
-		var out string
-		switch t {
-		case TypeLocal:
-			for _, from := range l.From {
-				path, err := GetPathRelativeToConfig(from)
-				if err != nil {
-					errors = append(errors, err)
-					goto after
-				}
-				cmd = append(cmd, path)
-			}
-			code, out, err = ExecuteResticCommand(backupOptions, cmd...)
-		case TypeVolume:
-			ok := CheckIfVolumeExists(l.From[0])
-			if !ok {
-				errors = append(errors, fmt.Errorf("volume \"%s\" does not exist", l.From[0]))
-				continue
-			}
-			cmd = append(cmd, "/data")
-			code, out, err = backend.ExecDocker(l, cmd)
-		}
-
-		// Extract metadata
-		md := metadata.ExtractMetadataFromBackupLog(out)
-		md.ExitCode = fmt.Sprint(code)
-		mdEnv := metadata.MakeEnvFromMetadata(&md)
-		for k, v := range mdEnv {
-			options.Envs[k+"_"+fmt.Sprint(i)] = v
-			options.Envs[k+"_"+strings.ToUpper(backend.name)] = v
-		}
-
-		// If error save it and continue
-		if err != nil {
-			colors.Error.Println(out)
-			errors = append(errors, fmt.Errorf("%s@%s:\n%s%s", l.name, backend.name, out, err))
-			continue
+	}
+	for _, result := range l.backupToBackends(backends, t, paths, cron) {
+		errors = append(errors, result.errors...)
+		if result.run != nil {
+			runs = append(runs, *result.run)
 		}
-
-		// Copy
-		if md.SnapshotID != "" {
-			for copyFrom, copyTo := range l.CopyOption {
-				b1, _ := GetBackend(copyFrom)
-				for _, copyToTarget := range copyTo {
-					b2, _ := GetBackend(copyToTarget)
-					colors.Secondary.Println("Copying " + copyFrom + " → " + copyToTarget)
-					env, _ := b1.getEnv()
-					env2, _ := b2.getEnv()
-					// Add the second repo to the env with a "2" suffix
-					for k, v := range env2 {
-						env[k+"2"] = v
-					}
-					_, _, err := ExecuteResticCommand(ExecuteOptions{
-						Envs: env,
-					}, "copy", md.SnapshotID)
-
-					if err != nil {
-						errors = append(errors, err)
-					}
-				}
-			}
+		for k, v := range result.env {
+			options.Envs[k] = v
 		}
 	}
 
@@ -317,35 +325,221 @@ after:
 
 	// Forget and optionally prune
 	if isSuccess && l.ForgetOption != "" && l.ForgetOption != LocationForgetNo {
-		err := l.Forget(l.ForgetOption == LocationForgetPrune, false)
+		// Only the backends that were backed up are locked
+		err := l.forget(l.ForgetOption == LocationForgetPrune, false, l.withCopyTargets(backends))
 		if err != nil {
 			errors = append(errors, err)
 		}
 	}
 
+	l.notify(notifications.NewEvent(l.name, start, runs, errors))
+
 	if len(errors) == 0 {
-		colors.Success.Println("Done")
+		colors.Success.Fprintln(l.out(), "Done")
 	}
 	return errors
 }
 
-func (l Location) Forget(prune bool, dry bool) error {
-	colors.PrimaryPrint("Forgetting for location \"%s\"", l.name)
+func (l Location) getPaths() ([]string, error) {
+	var paths []string
+	for _, from := range l.From {
+		path, err := GetPathRelativeToConfig(from)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// getParallel returns how many backends may be backed up at the same time.
+func (l Location) getParallel() int {
+	if l.Parallel > 0 {
+		return l.Parallel
+	}
+	return GetConfig().Global.Parallel
+}
+
+type backendResult struct {
+	errors []error
+	env    map[string]string
+	run    *history.Run
+}
+
+// backupToBackends backs up the location to the given backends, concurrently if configured.
+// The results keep the order of the backends, so that the numbering of the hook env variables is stable.
+func (l Location) backupToBackends(backends []string, t LocationType, paths []string, cron bool) []backendResult {
+	results := make([]backendResult, len(backends))
+	runConcurrently(len(backends), l.getParallel(), l.out(), func(i int, output io.Writer) {
+		results[i] = l.backupToBackend(i, backends[i], t, paths, cron, output)
+	})
+	return results
+}
+
+func (l Location) backupToBackend(i int, to string, t LocationType, paths []string, cron bool, output io.Writer) backendResult {
+	var result backendResult
+	backend, _ := GetBackend(to)
+	colors.Secondary.Fprintf(output, "Backend: %s\n", backend.name)
+	events.Emit(events.Event{Type: events.BackendStarted, Operation: history.OperationBackup, Location: l.name, Backend: backend.name})
+	release := acquireBackend(backend)
+	defer func() { release() }()
+	env, err := backend.getEnv()
+	if err != nil {
+		result.errors = append(result.errors, err)
+		return result
+	}
+
+	cmd := []string{"backup", "--json"}
+	cmd = append(cmd, combineAllOptions("backup", l, backend)...)
+	if cron {
+		cmd = append(cmd, "--tag", buildTag("cron"))
+	}
+	cmd = append(cmd, "--tag", l.getLocationTags())
+	// The JSON messages are not printed, only the summary below
+	backupOptions := ExecuteOptions{
+		Envs:   env,
+		Output: output,
+		Silent: true,
+	}
+
+	var code int = 0
+	var out string
+	start := time.Now()
+	switch t {
+	case TypeLocal:
+		cmd = append(cmd, paths...)
+		code, out, err = ExecuteResticCommand(backupOptions, cmd...)
+	case TypeVolume:
+		ok := CheckIfVolumeExists(l.From[0])
+		if !ok {
+			result.errors = append(result.errors, fmt.Errorf("volume \"%s\" does not exist", l.From[0]))
+			return result
+		}
+		cmd = append(cmd, "/data")
+		code, out, err = backend.execDocker(l, cmd, ExecuteOptions{Output: output, Silent: true})
+	}
+
+	if flags.VERBOSE && err == nil {
+		colors.Faint.Fprint(output, metadata.FormatBackupLog(out))
+	}
+
+	// Extract metadata
+	md := metadata.ExtractMetadataFromBackupLog(out)
+	md.ExitCode = code
+	if err == nil && md.SnapshotID != "" && md.ParentSnapshotID == "" {
+		// Only the snapshot itself knows its parent
+		if _, snapshot, err := ExecuteResticCommand(ExecuteOptions{Envs: env, Silent: true, Output: output}, "cat", "snapshot", md.SnapshotID); err == nil {
+			md.ParentSnapshotID = metadata.ExtractParentSnapshotID(snapshot)
+		}
+	}
+	run := l.saveRun(backend.name, cron, start, md, out, err)
+	result.run = &run
+	if md.SnapshotID != "" {
+		events.Emit(events.Event{Type: events.SnapshotSaved, Location: l.name, Backend: backend.name, Data: run})
+	}
+	result.env = make(map[string]string)
+	for k, v := range metadata.MakeEnvFromMetadata(&md) {
+		result.env[k+"_"+fmt.Sprint(i)] = v
+		result.env[k+"_"+strings.ToUpper(backend.name)] = v
+	}
+
+	// If error save it and continue
+	if err != nil {
+		colors.Error.Fprintln(output, out)
+		result.errors = append(result.errors, fmt.Errorf("%s@%s:\n%s%s", l.name, backend.name, out, err))
+		return result
+	}
+
+	// Copy
+	if md.SnapshotID != "" && len(l.CopyOption[backend.name]) > 0 {
+		// Both backends are taken together, holding on to the one backed up while waiting for a copy target could deadlock
+		release()
+		for _, copyToTarget := range l.CopyOption[backend.name] {
+			b2, _ := GetBackend(copyToTarget)
+			colors.Secondary.Fprintln(output, "Copying "+backend.name+" → "+copyToTarget)
+			env, _ := backend.getEnv()
+			env2, _ := b2.getEnv()
+			// Add the second repo to the env with a "2" suffix
+			for k, v := range env2 {
+				env[k+"2"] = v
+			}
+			releaseCopy := acquireBackends(backend, b2)
+			_, _, err := ExecuteResticCommand(ExecuteOptions{
+				Envs:   env,
+				Output: output,
+			}, "copy", md.SnapshotID)
+			releaseCopy()
+
+			if err != nil {
+				result.errors = append(result.errors, err)
+			}
+		}
+		release = acquireBackend(backend)
+	}
+
+	// Verify
+	if l.Verify.isEnabled() {
+		if err := l.verifyBackend(backend, output); err != nil {
+			result.errors = append(result.errors, err)
+		}
+	}
+	return result
+}
 
-	backendsToForget := l.To
-	for _, copyBackends := range l.CopyOption {
-		backendsToForget = append(backendsToForget, copyBackends...)
+// notify sends the result of a backup to the notifiers the location subscribed to.
+// Failing notifications are reported but do not fail the backup.
+func (l Location) notify(event notifications.Event) {
+	for _, n := range l.Notifications {
+		if !notifications.ShouldNotify(n.On, event) {
+			continue
+		}
+		notifier, err := notifications.New(n.Name, GetConfig().Notifications[n.Name])
+		if err == nil {
+			err = notifier.Send(event)
+		}
+		if err != nil {
+			colors.Error.Fprintln(l.out(), "Could not send notification:", err)
+		}
 	}
+}
 
-	for _, to := range backendsToForget {
+// saveRun records the backup to a single backend in the history file.
+func (l Location) saveRun(backend string, cron bool, start time.Time, md metadata.BackupLogMetadata, out string, err error) history.Run {
+	return saveRun(history.Run{
+		Operation:       history.OperationBackup,
+		Location:        l.name,
+		Backend:         backend,
+		Cron:            cron,
+		Start:           start,
+		ExitCode:        md.ExitCode,
+		SnapshotID:      md.SnapshotID,
+		AddedBytes:      md.AddedBytes,
+		FilesNew:        md.Files.Added,
+		FilesChanged:    md.Files.Changed,
+		FilesUnmodified: md.Files.Unmodified,
+	}, out, err)
+}
+
+func (l Location) Forget(prune bool, dry bool) error {
+	return l.forget(prune, dry, l.getBackends())
+}
+
+// forget removes old snapshots of the location from the given backends.
+func (l Location) forget(prune bool, dry bool, backends []string) error {
+	colors.PrimaryFprint(l.out(), "Forgetting for location \"%s\"", l.name)
+	events.Emit(events.Event{Type: events.LocationStarted, Operation: history.OperationForget, Location: l.name})
+
+	for _, to := range backends {
 		backend, _ := GetBackend(to)
-		colors.Secondary.Printf("For backend \"%s\"\n", backend.name)
+		colors.Secondary.Fprintf(l.out(), "For backend \"%s\"\n", backend.name)
+		events.Emit(events.Event{Type: events.BackendStarted, Operation: history.OperationForget, Location: l.name, Backend: backend.name})
 		env, err := backend.getEnv()
 		if err != nil {
 			return nil
 		}
 		options := ExecuteOptions{
-			Envs: env,
+			Envs:   env,
+			Output: l.out(),
 		}
 		cmd := []string{"forget", "--tag", l.getLocationTags()}
 		if prune {
@@ -355,12 +549,24 @@ func (l Location) Forget(prune bool, dry bool) error {
 			cmd = append(cmd, "--dry-run")
 		}
 		cmd = append(cmd, combineAllOptions("forget", l, backend)...)
-		_, _, err = ExecuteResticCommand(options, cmd...)
+		release := acquireBackend(backend)
+		start := time.Now()
+		code, out, err := ExecuteResticCommand(options, cmd...)
+		release()
+		if !dry {
+			saveRun(history.Run{
+				Operation: history.OperationForget,
+				Location:  l.name,
+				Backend:   backend.name,
+				Start:     start,
+				ExitCode:  code,
+			}, out, err)
+		}
 		if err != nil {
 			return err
 		}
 	}
-	colors.Success.Println("Done")
+	colors.Success.Fprintln(l.out(), "Done")
 	return nil
 }
 
@@ -373,88 +579,79 @@ func (l Location) hasBackend(backend string) bool {
 	return false
 }
 
-func buildRestoreCommand(l Location, to string, snapshot string, options []string) []string {
-	base := []string{"restore", "--target", to, "--tag", l.getLocationTags(), snapshot}
-	base = append(base, options...)
-	return base
+// getBackends returns all backends holding snapshots of the location, the targets followed by their copy targets.
+func (l Location) getBackends() []string {
+	return l.withCopyTargets(l.To)
 }
 
-func (l Location) Restore(to, from string, force bool, snapshot string, options []string) error {
-	if from == "" {
-		from = l.To[0]
-	} else if !l.hasBackend(from) {
-		return fmt.Errorf("invalid backend: \"%s\"", from)
-	}
-
-	if snapshot == "" {
-		snapshot = "latest"
+// withCopyTargets returns the given targets of the location followed by their copy targets.
+func (l Location) withCopyTargets(targets []string) []string {
+	backends := append([]string{}, targets...)
+	for _, to := range targets {
+		for _, copyTo := range l.CopyOption[to] {
+			if !ArrayContains(backends, copyTo) {
+				backends = append(backends, copyTo)
+			}
+		}
 	}
+	return backends
+}
 
-	colors.PrimaryPrint("Restoring location \"%s\"", l.name)
-	backend, _ := GetBackend(from)
-	colors.Secondary.Printf("Restoring %s@%s → %s\n", snapshot, backend.name, to)
-
-	t, err := l.getType()
-	if err != nil {
-		return err
-	}
-	switch t {
-	case TypeLocal:
-		to, err = filepath.Abs(to)
-		if err != nil {
-			return err
+// LockKeys returns the lock keys of the location and the backends it writes to, including copy targets.
+// If a backend is given only that one and its copy targets are included.
+func (l Location) LockKeys(backend string) []string {
+	keys := []string{lock.LocationKey(l.name)}
+	for _, to := range l.To {
+		if backend != "" && to != backend {
+			continue
 		}
-		// Check if target is empty
-		if !force {
-			notEmptyError := fmt.Errorf("target %s is not empty", to)
-			_, err = os.Stat(to)
-			if err == nil {
-				files, err := ioutil.ReadDir(to)
-				if err != nil {
-					return err
-				}
-				if len(files) > 0 {
-					return notEmptyError
-				}
-			} else {
-				if !os.IsNotExist(err) {
-					return err
-				}
-			}
+		keys = append(keys, lock.BackendKey(to))
+		for _, copyTo := range l.CopyOption[to] {
+			keys = append(keys, lock.BackendKey(copyTo))
 		}
-		err = backend.Exec(buildRestoreCommand(l, to, snapshot, options))
-	case TypeVolume:
-		_, _, err = backend.ExecDocker(l, buildRestoreCommand(l, "/", snapshot, options))
 	}
-	if err != nil {
-		return err
+	if backend != "" && !l.hasBackend(backend) {
+		keys = append(keys, lock.BackendKey(backend))
 	}
-	colors.Success.Println("Done")
-	return nil
+	return keys
 }
 
-func (l Location) RunCron() error {
+// claimCron returns whether a cron backup of the location is due. If so the location is locked and the run is stored right away.
+// A location locked by another instance is skipped and tried again once the retry interval has passed.
+func (l Location) claimCron() (bool, error) {
 	if l.Cron == "" {
-		return nil
+		return false, nil
 	}
 
 	schedule, err := cron.ParseStandard(l.Cron)
 	if err != nil {
-		return err
+		return false, err
 	}
 	last := time.Unix(lock.GetCron(l.name), 0)
 	next := schedule.Next(last)
 	now := time.Now()
 	if now.After(next) {
-		lock.SetCron(l.name, now.Unix())
-		errs := l.Backup(true, "")
-		if len(errs) > 0 {
-			return fmt.Errorf("Failed to backup location \"%s\":\n%w", l.name, errors.Join(errs...))
+		if err := lock.Acquire(l.LockKeys("")...); err != nil {
+			var locked *lock.LockedError
+			if errors.As(err, &locked) {
+				colors.Secondary.Printf("Skipping \"%s\", %s.\n", l.name, err)
+				lockedRetries[l.name] = now.Add(lockedRetryInterval)
+				return false, nil
+			}
+			return false, err
 		}
-	} else {
-		if !flags.CRON_LEAN {
-			colors.Body.Printf("Skipping \"%s\", not due yet.\n", l.name)
+		delete(lockedRetries, l.name)
+		// Another instance might have run the backup while holding the lock
+		if !now.After(schedule.Next(time.Unix(lock.GetCron(l.name), 0))) {
+			lock.Release(l.LockKeys("")...)
+			colors.Body.Printf("Skipping \"%s\", it was just backed up by another instance.\n", l.name)
+			return false, nil
 		}
+		lock.SetCron(l.name, now.Unix())
+		return true, nil
 	}
-	return nil
+	if !flags.CRON_LEAN {
+		colors.Body.Printf("Skipping \"%s\", not due yet.\n", l.name)
+	}
+	return false, nil
 }
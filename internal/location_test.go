@@ -91,3 +91,46 @@ func TestBuildRestoreCommand(t *testing.T) {
 	expected := []string{"restore", "--target", "to", "--tag", "ar:location:foo", "snapshot", "options"}
 	assertSliceEqual(t, result, expected)
 }
+
+func TestGetParallel(t *testing.T) {
+	config = &Config{Global: Global{Parallel: 3}}
+	t.Cleanup(func() { config = nil })
+
+	t.Run("global", func(t *testing.T) {
+		assertEqual(t, Location{}.getParallel(), 3)
+	})
+
+	t.Run("location overrides global", func(t *testing.T) {
+		assertEqual(t, Location{Parallel: 2}.getParallel(), 2)
+	})
+}
+
+func TestLockKeys(t *testing.T) {
+	l := Location{
+		name:       "foo",
+		To:         []string{"nas", "b2"},
+		CopyOption: LocationCopy{"nas": []string{"remote"}},
+	}
+
+	t.Run("all backends", func(t *testing.T) {
+		result := l.LockKeys("")
+		expected := []string{"location:foo", "backend:nas", "backend:remote", "backend:b2"}
+		assertSliceEqual(t, result, expected)
+	})
+
+	t.Run("specific backend", func(t *testing.T) {
+		result := l.LockKeys("b2")
+		expected := []string{"location:foo", "backend:b2"}
+		assertSliceEqual(t, result, expected)
+	})
+}
+
+func TestGetBackends(t *testing.T) {
+	l := Location{
+		To:         []string{"nas", "b2"},
+		CopyOption: LocationCopy{"nas": []string{"remote"}, "b2": []string{"remote", "tape"}},
+	}
+	result := l.getBackends()
+	expected := []string{"nas", "b2", "remote", "tape"}
+	assertSliceEqual(t, result, expected)
+}
@@ -1,13 +1,22 @@
 package lock
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cupcakearmy/autorestic/internal/colors"
 	"github.com/cupcakearmy/autorestic/internal/flags"
 	"github.com/spf13/viper"
+	"golang.org/x/sys/unix"
 )
 
 var lock *viper.Viper
@@ -15,21 +24,63 @@ var file string
 var once sync.Once
 
 const (
-	RUNNING = "running"
+	// Directory next to the config holding one file per held lock
+	LOCKS_DIR = ".autorestic.locks"
+
+	keyLocation = "location"
+	keyBackend  = "backend"
 )
 
+// How often a locked key is checked again while waiting for it
+var pollInterval = time.Second
+
+// Lock files of the keys held by this process
+var held = map[string]*os.File{}
+var heldMutex sync.Mutex
+
+// Holder describes the process holding a lock.
+type Holder struct {
+	Key      string    `json:"key"`
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Start    time.Time `json:"start"`
+	Command  string    `json:"command"`
+
+	// Set by List if the holder is not running anymore
+	Stale bool `json:"-"`
+}
+
+func (h Holder) String() string {
+	if h.PID == 0 {
+		return "an unknown instance"
+	}
+	return fmt.Sprintf("\"%s\" (pid %d on %s, started %s)", h.Command, h.PID, h.Hostname, h.Start.Local().Format(time.DateTime))
+}
+
+// LockedError is returned when a key is already held by another process.
+type LockedError struct {
+	Holder Holder
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is locked by %s", DescribeKey(e.Holder.Key), e.Holder)
+}
+
+func getConfigDir() string {
+	p := viper.ConfigFileUsed()
+	if p == "" {
+		colors.Error.Println("cannot lock before reading config location")
+		os.Exit(1)
+	}
+	return path.Dir(p)
+}
+
 func getLock() *viper.Viper {
 	if lock == nil {
 
 		once.Do(func() {
 			lock = viper.New()
-			lock.SetDefault("running", false)
-			p := viper.ConfigFileUsed()
-			if p == "" {
-				colors.Error.Println("cannot lock before reading config location")
-				os.Exit(1)
-			}
-			file = path.Join(path.Dir(p), ".autorestic.lock.yml")
+			file = path.Join(getConfigDir(), ".autorestic.lock.yml")
 			if !flags.CRON_LEAN {
 				colors.Faint.Println("Using lock:\t", file)
 			}
@@ -41,17 +92,30 @@ func getLock() *viper.Viper {
 	return lock
 }
 
+// Reset releases all keys and forgets the lock file, so that it is looked up again next to the config in use.
+func Reset() {
+	ReleaseAll()
+	lock = nil
+	file = ""
+	once = sync.Once{}
+}
+
 func setLockValue(key string, value interface{}) (*viper.Viper, error) {
 	lock := getLock()
 
-	if key == RUNNING {
-		value := value.(bool)
-		if value && lock.GetBool(key) {
-			colors.Error.Println("an instance is already running. exiting")
-			os.Exit(1)
-		}
+	// Other instances might update the file at the same time
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		return nil, err
 	}
+	defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
 
+	// Pick up values written by other instances in the meantime
+	lock.ReadInConfig()
 	lock.Set(key, value)
 	if err := lock.WriteConfigAs(file); err != nil {
 		return nil, err
@@ -59,20 +123,278 @@ func setLockValue(key string, value interface{}) (*viper.Viper, error) {
 	return lock, nil
 }
 
+// GetCron returns the time of the last cron run of a location. The lock file is read again,
+// as other instances might have stored a run in the meantime.
 func GetCron(location string) int64 {
-	return getLock().GetInt64("cron." + location)
+	lock := getLock()
+	if f, err := os.Open(file); err == nil {
+		defer f.Close()
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err == nil {
+			defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
+			lock.ReadInConfig()
+		}
+	}
+	return lock.GetInt64("cron." + location)
 }
 
 func SetCron(location string, value int64) {
 	setLockValue("cron."+location, value)
 }
 
-func Lock() error {
-	_, err := setLockValue(RUNNING, true)
-	return err
+// LocationKey returns the lock key of a location.
+func LocationKey(name string) string {
+	return keyLocation + ":" + name
+}
+
+// BackendKey returns the lock key of a backend.
+func BackendKey(name string) string {
+	return keyBackend + ":" + name
+}
+
+// DescribeKey returns a human readable description of a lock key, e.g. location "foo".
+func DescribeKey(key string) string {
+	kind, name, _ := strings.Cut(key, ":")
+	return fmt.Sprintf("%s \"%s\"", kind, name)
+}
+
+func getLocksDir() string {
+	return path.Join(getConfigDir(), LOCKS_DIR)
+}
+
+func getKeyFile(key string) string {
+	kind, name, _ := strings.Cut(key, ":")
+	return path.Join(getLocksDir(), kind+"-"+strings.ReplaceAll(name, string(os.PathSeparator), "_")+".lock")
+}
+
+func readHolder(f *os.File) (*Holder, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, math.MaxInt64))
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+	var holder Holder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", f.Name(), err)
+	}
+	return &holder, nil
+}
+
+func isProcessAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || errors.Is(err, unix.EPERM)
 }
 
-func Unlock() error {
-	_, err := setLockValue(RUNNING, false)
+// isStale returns whether the holder of a lock is known not to be running anymore.
+// Processes on other hosts cannot be checked and are assumed to be running.
+func isStale(holder *Holder, hostname string) bool {
+	return holder.Hostname == hostname && !isProcessAlive(holder.PID)
+}
+
+// acquireKey takes the file lock of a key and records this process as the holder.
+// As the file lock is released by the OS when a process dies, a holder left in the file is stale.
+func acquireKey(key string) (*os.File, error) {
+	hostname, _ := os.Hostname()
+	f, err := os.OpenFile(getKeyFile(key), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	flockErr := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	holder, err := readHolder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if holder != nil {
+		switch {
+		case errors.Is(flockErr, unix.EWOULDBLOCK):
+			f.Close()
+			return nil, &LockedError{Holder: *holder}
+		case flockErr != nil || holder.Hostname != hostname:
+			// Without a file lock, or for holders on other hosts, only the pid can tell whether the lock is stale
+			if !isStale(holder, hostname) {
+				f.Close()
+				return nil, &LockedError{Holder: *holder}
+			}
+		}
+		colors.Faint.Printf("Removing stale lock of %s held by %s\n", DescribeKey(key), holder)
+	} else if errors.Is(flockErr, unix.EWOULDBLOCK) {
+		// The holder has not been written yet
+		f.Close()
+		return nil, &LockedError{Holder: Holder{Key: key}}
+	}
+
+	holder = &Holder{
+		Key:      key,
+		PID:      os.Getpid(),
+		Hostname: hostname,
+		Start:    time.Now(),
+		Command:  strings.Join(os.Args, " "),
+	}
+	data, _ := json.Marshal(holder)
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// releaseKey clears the holder and releases the file lock.
+// The file itself is kept, as removing it could let two instances lock different files for the same key.
+func releaseKey(f *os.File) error {
+	defer f.Close()
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
+
+// Acquire locks all given keys or none of them.
+// Keys already held by this process are skipped.
+// If another instance holds one of the keys, Acquire waits for it to be released when --wait or --lock-timeout is given.
+func Acquire(keys ...string) error {
+	err := tryAcquire(keys)
+	var locked *LockedError
+	if !errors.As(err, &locked) || (!flags.LOCK_WAIT && flags.LOCK_TIMEOUT <= 0) {
+		return err
+	}
+
+	colors.Secondary.Printf("Waiting, %s\n", err)
+	var timeout <-chan time.Time
+	if flags.LOCK_TIMEOUT > 0 {
+		timeout = time.After(flags.LOCK_TIMEOUT)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out after %s waiting for the lock: %w", flags.LOCK_TIMEOUT, err)
+		case <-ticker.C:
+		}
+		err = tryAcquire(keys)
+		if !errors.As(err, &locked) {
+			return err
+		}
+	}
+}
+
+func tryAcquire(keys []string) error {
+	heldMutex.Lock()
+	defer heldMutex.Unlock()
+
+	if err := os.MkdirAll(getLocksDir(), 0755); err != nil {
+		return err
+	}
+
+	// A stable order prevents two instances from taking the same keys crosswise
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	var acquired []string
+	for _, key := range sorted {
+		if held[key] != nil {
+			continue
+		}
+		f, err := acquireKey(key)
+		if err != nil {
+			for _, k := range acquired {
+				releaseKey(held[k])
+				delete(held, k)
+			}
+			return err
+		}
+		held[key] = f
+		acquired = append(acquired, key)
+	}
+	return nil
+}
+
+// Release unlocks the given keys held by this process.
+func Release(keys ...string) error {
+	heldMutex.Lock()
+	defer heldMutex.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		f := held[key]
+		if f == nil {
+			continue
+		}
+		if err := releaseKey(f); err != nil {
+			errs = append(errs, err)
+		}
+		delete(held, key)
+	}
+	return errors.Join(errs...)
+}
+
+// ReleaseAll unlocks every key held by this process.
+func ReleaseAll() error {
+	heldMutex.Lock()
+	var keys []string
+	for key := range held {
+		keys = append(keys, key)
+	}
+	heldMutex.Unlock()
+	return Release(keys...)
+}
+
+// List returns the holders of all current locks, marking the ones that are not running anymore as stale.
+func List() ([]Holder, error) {
+	entries, err := os.ReadDir(getLocksDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	var holders []Holder
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".lock" {
+			continue
+		}
+		f, err := os.Open(path.Join(getLocksDir(), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		holder, err := readHolder(f)
+		if err == nil && holder != nil {
+			heldMutex.Lock()
+			heldByUs := held[holder.Key] != nil
+			heldMutex.Unlock()
+			if !heldByUs {
+				flockErr := unix.Flock(int(f.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+				if flockErr == nil && holder.Hostname == hostname {
+					holder.Stale = true
+				} else if !errors.Is(flockErr, unix.EWOULDBLOCK) {
+					holder.Stale = isStale(holder, hostname)
+				}
+			}
+			holders = append(holders, *holder)
+		}
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return holders, nil
+}
+
+// Remove forcefully deletes a lock, regardless of who is holding it.
+func Remove(key string) error {
+	heldMutex.Lock()
+	defer heldMutex.Unlock()
+
+	if f := held[key]; f != nil {
+		delete(held, key)
+		return releaseKey(f)
+	}
+	err := os.Remove(getKeyFile(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	return err
 }
@@ -1,12 +1,19 @@
 package lock
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/cupcakearmy/autorestic/internal/flags"
 	"github.com/spf13/viper"
 )
 
@@ -14,80 +21,195 @@ var testDirectory = "autorestic_test_tmp"
 
 // All tests must share the same lock file as it is only initialized once
 func setup(t *testing.T) {
-	d, err := os.MkdirTemp("", testDirectory)
-	if err != nil {
-		log.Fatalf("error creating temp dir: %v", err)
-		return
+	// sub processes share the directory of the parent test
+	d := os.Getenv("LOCK_TEST_DIR")
+	if d == "" {
+		var err error
+		d, err = os.MkdirTemp("", testDirectory)
+		if err != nil {
+			log.Fatalf("error creating temp dir: %v", err)
+			return
+		}
+		os.Setenv("LOCK_TEST_DIR", d)
 	}
 	// set config file location
 	viper.SetConfigFile(d + "/.autorestic.yml")
 
 	t.Cleanup(func() {
 		os.RemoveAll(d)
+		os.Unsetenv("LOCK_TEST_DIR")
 		viper.Reset()
 	})
 }
 
+// startHolder runs the "locked by running instance" test in another process, which locks location "foo" until it is killed.
+// this trick to run code in another process is discussed here:
+// https://talks.golang.org/2014/testing.slide#23
+func startHolder(t *testing.T) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=TestLock/locked_by_running_instance")
+	cmd.Env = append(os.Environ(), "HOLD=1")
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	if line != "locked\n" {
+		t.Fatalf("got %q, want %q", line, "locked\n")
+	}
+	return cmd
+}
+
 func TestLock(t *testing.T) {
 	setup(t)
 
-	t.Run("getLock", func(t *testing.T) {
-		result := getLock().GetBool(RUNNING)
-
-		if result {
-			t.Errorf("got %v, want %v", result, false)
+	t.Run("acquire", func(t *testing.T) {
+		err := Acquire(LocationKey("foo"), BackendKey("bar"))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
 		}
-	})
 
-	t.Run("lock", func(t *testing.T) {
-		lock, err := setLockValue(RUNNING, true)
+		holders, err := List()
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
+		if len(holders) != 2 {
+			t.Errorf("got %d locks, want %d", len(holders), 2)
+		}
+		for _, holder := range holders {
+			if holder.PID != os.Getpid() {
+				t.Errorf("got pid %d, want %d", holder.PID, os.Getpid())
+			}
+		}
+	})
 
-		result := lock.GetBool(RUNNING)
-		if !result {
-			t.Errorf("got %v, want %v", result, true)
+	t.Run("acquire held key again", func(t *testing.T) {
+		err := Acquire(LocationKey("foo"))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
 		}
 	})
 
-	t.Run("unlock", func(t *testing.T) {
-		lock, err := setLockValue(RUNNING, false)
+	t.Run("release", func(t *testing.T) {
+		err := ReleaseAll()
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
 
-		result := lock.GetBool(RUNNING)
-		if result {
-			t.Errorf("got %v, want %v", result, false)
+		holders, _ := List()
+		if len(holders) != 0 {
+			t.Errorf("got %d locks, want %d", len(holders), 0)
 		}
 	})
 
-	// locking a locked instance exits the instance
-	// this trick to capture os.Exit(1) is discussed here:
-	// https://talks.golang.org/2014/testing.slide#23
-	t.Run("lock twice", func(t *testing.T) {
-		if os.Getenv("CRASH") == "1" {
-			err := Lock()
-			if err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-			// should fail
-			Lock()
+	t.Run("locked by running instance", func(t *testing.T) {
+		if os.Getenv("HOLD") == "1" {
+			Acquire(LocationKey("foo"))
+			fmt.Println("locked")
+			time.Sleep(time.Minute)
+			return
 		}
+		cmd := startHolder(t)
 
-		cmd := exec.Command(os.Args[0], "-test.run=TestLock/lock_twice")
-		cmd.Env = append(os.Environ(), "CRASH=1")
-		err := cmd.Run()
+		err := Acquire(BackendKey("bar"), LocationKey("foo"))
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("got %v, want a locked error", err)
+		}
+		if locked.Holder.PID != cmd.Process.Pid {
+			t.Errorf("got pid %d, want %d", locked.Holder.PID, cmd.Process.Pid)
+		}
+		hostname, _ := os.Hostname()
+		if locked.Holder.Hostname != hostname {
+			t.Errorf("got hostname %q, want %q", locked.Holder.Hostname, hostname)
+		}
+		expected := "location \"foo\" is locked by"
+		if !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("got %q, want prefix %q", err.Error(), expected)
+		}
 
-		err, ok := err.(*exec.ExitError)
-		if !ok {
-			t.Error("unexpected error")
+		// nothing is taken if one key is locked
+		holders, _ := List()
+		if len(holders) != 1 {
+			t.Fatalf("got %d locks, want %d", len(holders), 1)
 		}
-		expected := "exit status 1"
-		if err.Error() != expected {
-			t.Errorf("got %q, want %q", err.Error(), expected)
+		if holders[0].Stale {
+			t.Error("running instance reported as stale")
+		}
+	})
+
+	t.Run("stale lock of killed instance", func(t *testing.T) {
+		holders, _ := List()
+		if len(holders) != 1 || !holders[0].Stale {
+			t.Fatalf("got %v, want one stale lock", holders)
+		}
+
+		if err := Acquire(LocationKey("foo")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		holders, _ = List()
+		if len(holders) != 1 || holders[0].PID != os.Getpid() {
+			t.Errorf("got %v, want lock held by this instance", holders)
+		}
+		ReleaseAll()
+	})
+
+	t.Run("wait for running instance", func(t *testing.T) {
+		pollInterval = 10 * time.Millisecond
+		t.Cleanup(func() {
+			pollInterval = time.Second
+			flags.LOCK_WAIT = false
+			flags.LOCK_TIMEOUT = 0
+		})
+
+		cmd := startHolder(t)
+		flags.LOCK_TIMEOUT = 50 * time.Millisecond
+		err := Acquire(LocationKey("foo"))
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("got %v, want a locked error", err)
+		}
+		expected := "timed out after 50ms waiting for the lock"
+		if !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("got %q, want prefix %q", err.Error(), expected)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cmd.Process.Kill()
+		}()
+		flags.LOCK_TIMEOUT = 0
+		flags.LOCK_WAIT = true
+		if err := Acquire(LocationKey("foo")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ReleaseAll()
+	})
+
+	t.Run("locked by other host", func(t *testing.T) {
+		holder := Holder{Key: LocationKey("foo"), PID: 1, Hostname: "other-host", Start: time.Now(), Command: "autorestic backup -a"}
+		data, _ := json.Marshal(holder)
+		os.WriteFile(getKeyFile(LocationKey("foo")), data, 0644)
+
+		err := Acquire(LocationKey("foo"))
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("got %v, want a locked error", err)
+		}
+		if locked.Holder.Hostname != "other-host" {
+			t.Errorf("got hostname %q, want %q", locked.Holder.Hostname, "other-host")
+		}
+
+		if err := Remove(LocationKey("foo")); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if err := Acquire(LocationKey("foo")); err != nil {
+			t.Errorf("unexpected error: %v", err)
 		}
+		ReleaseAll()
 	})
 
 	t.Run("set cron", func(t *testing.T) {
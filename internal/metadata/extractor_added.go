@@ -2,7 +2,6 @@ package metadata
 
 import (
 	"regexp"
-	"strings"
 )
 
 type addedExtractor struct {
@@ -14,7 +13,7 @@ func (e addedExtractor) Matches(line string) bool {
 }
 func (e addedExtractor) Extract(metadata *BackupLogMetadata, line string) {
 	// Sample line: "Added to the repo: 0 B"
-	metadata.AddedSize = strings.TrimSpace(e.re.ReplaceAllString(line, "$2"))
+	metadata.AddedBytes = ParseBytes(e.re.ReplaceAllString(line, "$2"))
 }
 
 func NewAddedExtractor() MetadatExtractor {
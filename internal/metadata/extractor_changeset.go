@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -19,9 +20,9 @@ func (e ChangeSetExtractor) Extract(metadata *BackupLogMetadata, line string) {
 	trimmed := strings.TrimSpace(e.re.ReplaceAllString(line, ""))
 	splitted := strings.Split(trimmed, ",")
 	var changeset BackupLogMetadataChangeset = BackupLogMetadataChangeset{}
-	changeset.Added = e.cleaner.ReplaceAllString(splitted[0], "")
-	changeset.Changed = e.cleaner.ReplaceAllString(splitted[1], "")
-	changeset.Unmodified = e.cleaner.ReplaceAllString(splitted[2], "")
+	changeset.Added, _ = strconv.ParseInt(e.cleaner.ReplaceAllString(splitted[0], ""), 10, 64)
+	changeset.Changed, _ = strconv.ParseInt(e.cleaner.ReplaceAllString(splitted[1], ""), 10, 64)
+	changeset.Unmodified, _ = strconv.ParseInt(e.cleaner.ReplaceAllString(splitted[2], ""), 10, 64)
 	e.saver.Save(metadata, changeset)
 }
 
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -17,10 +18,10 @@ func (e processedExtractor) Extract(metadata *BackupLogMetadata, line string) {
 	// Sample line: "processed 2 files, 24 B in 0:00"
 	var processed = BackupLogMetadataProcessed{}
 	split := strings.Split(line, "in")
-	processed.Duration = strings.TrimSpace(split[1])
+	processed.Duration = ParseDuration(split[1])
 	split = strings.Split(split[0], ",")
-	processed.Files = e.cleaner.ReplaceAllString(split[0], "")
-	processed.Size = strings.TrimSpace(split[1])
+	processed.Files, _ = strconv.ParseInt(e.cleaner.ReplaceAllString(split[0], ""), 10, 64)
+	processed.Bytes = ParseBytes(split[1])
 	metadata.Processed = processed
 }
 
@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+}
+
+// FormatBytes prints a size the same way restic does, e.g. "1.234 MiB".
+func FormatBytes(bytes int64) string {
+	for _, unit := range byteUnits {
+		if bytes >= unit.size {
+			return fmt.Sprintf("%.3f %s", float64(bytes)/float64(unit.size), unit.name)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// ParseBytes is the inverse of FormatBytes.
+func ParseBytes(s string) int64 {
+	parts := strings.Fields(s)
+	if len(parts) != 2 {
+		return 0
+	}
+	value, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0
+	}
+	for _, unit := range byteUnits {
+		if strings.EqualFold(parts[1], unit.name) {
+			return int64(value * float64(unit.size))
+		}
+	}
+	return int64(value)
+}
+
+// FormatDuration prints a duration the same way restic does, e.g. "1:02" or "1:02:03".
+func FormatDuration(d time.Duration) string {
+	sec := int64(d / time.Second)
+	hours := sec / 3600
+	sec -= hours * 3600
+	min := sec / 60
+	sec -= min * 60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, min, sec)
+	}
+	return fmt.Sprintf("%d:%02d", min, sec)
+}
+
+// ParseDuration is the inverse of FormatDuration.
+func ParseDuration(s string) time.Duration {
+	var total int64
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + value
+	}
+	return time.Duration(total) * time.Second
+}
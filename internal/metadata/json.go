@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message types printed by "restic backup --json", one object per line.
+const (
+	messageTypeStatus  = "status"
+	messageTypeSummary = "summary"
+)
+
+type backupMessage struct {
+	MessageType string `json:"message_type"`
+}
+
+type backupStatus struct {
+	SecondsElapsed int64 `json:"seconds_elapsed"`
+	FilesDone      int64 `json:"files_done"`
+	BytesDone      int64 `json:"bytes_done"`
+}
+
+type backupSummary struct {
+	FilesNew            int64   `json:"files_new"`
+	FilesChanged        int64   `json:"files_changed"`
+	FilesUnmodified     int64   `json:"files_unmodified"`
+	DirsNew             int64   `json:"dirs_new"`
+	DirsChanged         int64   `json:"dirs_changed"`
+	DirsUnmodified      int64   `json:"dirs_unmodified"`
+	DataAdded           int64   `json:"data_added"`
+	TotalFilesProcessed int64   `json:"total_files_processed"`
+	TotalBytesProcessed int64   `json:"total_bytes_processed"`
+	TotalDuration       float64 `json:"total_duration"`
+	SnapshotID          string  `json:"snapshot_id"`
+}
+
+// extractMetadataFromJSON returns false if the log does not contain any JSON messages.
+func extractMetadataFromJSON(log string) (BackupLogMetadata, bool) {
+	var md BackupLogMetadata
+	found := false
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var msg backupMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue
+		}
+		switch msg.MessageType {
+		case messageTypeStatus:
+			// Only used if the backup did not finish and no summary is printed
+			var status backupStatus
+			if err := json.Unmarshal([]byte(line), &status); err != nil {
+				continue
+			}
+			md.Processed = BackupLogMetadataProcessed{
+				Files:    status.FilesDone,
+				Bytes:    status.BytesDone,
+				Duration: time.Duration(status.SecondsElapsed) * time.Second,
+			}
+			found = true
+		case messageTypeSummary:
+			var summary backupSummary
+			if err := json.Unmarshal([]byte(line), &summary); err != nil {
+				continue
+			}
+			md.Files = BackupLogMetadataChangeset{
+				Added:      summary.FilesNew,
+				Changed:    summary.FilesChanged,
+				Unmodified: summary.FilesUnmodified,
+			}
+			md.Dirs = BackupLogMetadataChangeset{
+				Added:      summary.DirsNew,
+				Changed:    summary.DirsChanged,
+				Unmodified: summary.DirsUnmodified,
+			}
+			md.AddedBytes = summary.DataAdded
+			md.Processed = BackupLogMetadataProcessed{
+				Files:    summary.TotalFilesProcessed,
+				Bytes:    summary.TotalBytesProcessed,
+				Duration: time.Duration(summary.TotalDuration * float64(time.Second)),
+			}
+			md.SnapshotID = summary.SnapshotID
+			found = true
+		}
+	}
+	return md, found
+}
+
+// FormatBackupLog returns the summary of the JSON output of "restic backup" the way restic prints it without --json.
+// The status messages are left out, logs that are not JSON are returned as they are.
+func FormatBackupLog(log string) string {
+	md, ok := extractMetadataFromJSON(log)
+	if !ok {
+		return log
+	}
+	if md.SnapshotID == "" {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Files:       %5d new, %5d changed, %5d unmodified\n", md.Files.Added, md.Files.Changed, md.Files.Unmodified)
+	fmt.Fprintf(&b, "Dirs:        %5d new, %5d changed, %5d unmodified\n", md.Dirs.Added, md.Dirs.Changed, md.Dirs.Unmodified)
+	fmt.Fprintf(&b, "Added to the repository: %s\n\n", FormatBytes(md.AddedBytes))
+	fmt.Fprintf(&b, "processed %d files, %s in %s\n", md.Processed.Files, FormatBytes(md.Processed.Bytes), FormatDuration(md.Processed.Duration))
+	fmt.Fprintf(&b, "snapshot %s saved\n", md.SnapshotID)
+	return b.String()
+}
+
+// ExtractParentSnapshotID reads the parent of a snapshot from the output of "restic cat snapshot".
+// The JSON output of "restic backup" does not contain it.
+func ExtractParentSnapshotID(snapshot string) string {
+	var s struct {
+		Parent string `json:"parent"`
+	}
+	if err := json.Unmarshal([]byte(snapshot), &s); err != nil {
+		return ""
+	}
+	return s.Parent
+}
@@ -1,27 +1,29 @@
 package metadata
 
 import (
+	"fmt"
 	"strings"
+	"time"
 )
 
 type BackupLogMetadataChangeset struct {
-	Added      string
-	Changed    string
-	Unmodified string
+	Added      int64
+	Changed    int64
+	Unmodified int64
 }
 type BackupLogMetadataProcessed struct {
-	Files    string
-	Size     string
-	Duration string
+	Files    int64
+	Bytes    int64
+	Duration time.Duration
 }
 type BackupLogMetadata struct {
 	ParentSnapshotID string
 	Files            BackupLogMetadataChangeset
 	Dirs             BackupLogMetadataChangeset
-	AddedSize        string
+	AddedBytes       int64
 	Processed        BackupLogMetadataProcessed
 	SnapshotID       string
-	ExitCode         string
+	ExitCode         int
 }
 
 type MetadatExtractor interface {
@@ -38,7 +40,16 @@ var extractors = []MetadatExtractor{
 	NewSnapshotExtractor(),
 }
 
+// ExtractMetadataFromBackupLog reads the output of "restic backup".
+// The JSON output (--json) is preferred, the human readable log is only parsed for restic versions that do not support it.
 func ExtractMetadataFromBackupLog(log string) BackupLogMetadata {
+	if md, ok := extractMetadataFromJSON(log); ok {
+		return md
+	}
+	return extractMetadataFromText(log)
+}
+
+func extractMetadataFromText(log string) BackupLogMetadata {
 	var md BackupLogMetadata
 	for _, line := range strings.Split(log, "\n") {
 		line = strings.TrimSpace(line)
@@ -58,17 +69,19 @@ func MakeEnvFromMetadata(metadata *BackupLogMetadata) map[string]string {
 
 	env[prefix+"SNAPSHOT_ID"] = metadata.SnapshotID
 	env[prefix+"PARENT_SNAPSHOT_ID"] = metadata.ParentSnapshotID
-	env[prefix+"FILES_ADDED"] = metadata.Files.Added
-	env[prefix+"FILES_CHANGED"] = metadata.Files.Changed
-	env[prefix+"FILES_UNMODIFIED"] = metadata.Files.Unmodified
-	env[prefix+"DIRS_ADDED"] = metadata.Dirs.Added
-	env[prefix+"DIRS_CHANGED"] = metadata.Dirs.Changed
-	env[prefix+"DIRS_UNMODIFIED"] = metadata.Dirs.Unmodified
-	env[prefix+"ADDED_SIZE"] = metadata.AddedSize
-	env[prefix+"PROCESSED_FILES"] = metadata.Processed.Files
-	env[prefix+"PROCESSED_SIZE"] = metadata.Processed.Size
-	env[prefix+"PROCESSED_DURATION"] = metadata.Processed.Duration
-	env[prefix+"EXIT_CODE"] = metadata.ExitCode
+	env[prefix+"FILES_ADDED"] = fmt.Sprint(metadata.Files.Added)
+	env[prefix+"FILES_CHANGED"] = fmt.Sprint(metadata.Files.Changed)
+	env[prefix+"FILES_UNMODIFIED"] = fmt.Sprint(metadata.Files.Unmodified)
+	env[prefix+"DIRS_ADDED"] = fmt.Sprint(metadata.Dirs.Added)
+	env[prefix+"DIRS_CHANGED"] = fmt.Sprint(metadata.Dirs.Changed)
+	env[prefix+"DIRS_UNMODIFIED"] = fmt.Sprint(metadata.Dirs.Unmodified)
+	env[prefix+"ADDED_SIZE"] = FormatBytes(metadata.AddedBytes)
+	env[prefix+"ADDED_BYTES"] = fmt.Sprint(metadata.AddedBytes)
+	env[prefix+"PROCESSED_FILES"] = fmt.Sprint(metadata.Processed.Files)
+	env[prefix+"PROCESSED_SIZE"] = FormatBytes(metadata.Processed.Bytes)
+	env[prefix+"PROCESSED_BYTES"] = fmt.Sprint(metadata.Processed.Bytes)
+	env[prefix+"PROCESSED_DURATION"] = FormatDuration(metadata.Processed.Duration)
+	env[prefix+"EXIT_CODE"] = fmt.Sprint(metadata.ExitCode)
 
 	return env
 }
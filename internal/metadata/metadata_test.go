@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonLog = `{"message_type":"status","seconds_elapsed":1,"percent_done":0.5,"total_files":2,"files_done":1,"total_bytes":24,"bytes_done":12}
+{"message_type":"summary","files_new":1,"files_changed":2,"files_unmodified":3,"dirs_new":4,"dirs_changed":5,"dirs_unmodified":6,"data_blobs":1,"tree_blobs":1,"data_added":2048,"total_files_processed":6,"total_bytes_processed":1572864,"total_duration":62.5,"snapshot_id":"917c7691"}
+`
+
+const textLog = `using parent snapshot c65d9310
+
+Files:           1 new,     2 changed,     3 unmodified
+Dirs:            4 new,     5 changed,     6 unmodified
+Added to the repository: 2.000 KiB (1.234 KiB stored)
+
+processed 6 files, 1.500 MiB in 1:02
+snapshot 917c7691 saved
+`
+
+func TestExtractMetadataFromBackupLog(t *testing.T) {
+	for name, log := range map[string]string{"json": jsonLog, "text": textLog} {
+		t.Run(name, func(t *testing.T) {
+			md := ExtractMetadataFromBackupLog(log)
+			assert.Equal(t, "917c7691", md.SnapshotID)
+			assert.Equal(t, BackupLogMetadataChangeset{Added: 1, Changed: 2, Unmodified: 3}, md.Files)
+			assert.Equal(t, BackupLogMetadataChangeset{Added: 4, Changed: 5, Unmodified: 6}, md.Dirs)
+			assert.Equal(t, int64(2048), md.AddedBytes)
+			assert.Equal(t, int64(6), md.Processed.Files)
+			assert.Equal(t, int64(1572864), md.Processed.Bytes)
+			assert.Equal(t, 62*time.Second, md.Processed.Duration.Truncate(time.Second))
+		})
+	}
+
+	t.Run("status only", func(t *testing.T) {
+		md := ExtractMetadataFromBackupLog(`{"message_type":"status","seconds_elapsed":3,"files_done":7,"bytes_done":99}`)
+		assert.Equal(t, BackupLogMetadataProcessed{Files: 7, Bytes: 99, Duration: 3 * time.Second}, md.Processed)
+		assert.Equal(t, "", md.SnapshotID)
+	})
+}
+
+func TestMakeEnvFromMetadata(t *testing.T) {
+	md := ExtractMetadataFromBackupLog(jsonLog)
+	md.ExitCode = 3
+	env := MakeEnvFromMetadata(&md)
+	assert.Equal(t, "917c7691", env["AUTORESTIC_SNAPSHOT_ID"])
+	assert.Equal(t, "1", env["AUTORESTIC_FILES_ADDED"])
+	assert.Equal(t, "2.000 KiB", env["AUTORESTIC_ADDED_SIZE"])
+	assert.Equal(t, "2048", env["AUTORESTIC_ADDED_BYTES"])
+	assert.Equal(t, "1.500 MiB", env["AUTORESTIC_PROCESSED_SIZE"])
+	assert.Equal(t, "1:02", env["AUTORESTIC_PROCESSED_DURATION"])
+	assert.Equal(t, "3", env["AUTORESTIC_EXIT_CODE"])
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "0 B", FormatBytes(0))
+	assert.Equal(t, "1023 B", FormatBytes(1023))
+	assert.Equal(t, "1.000 GiB", FormatBytes(1<<30))
+	assert.Equal(t, int64(1<<30), ParseBytes("1.000 GiB"))
+	assert.Equal(t, int64(24), ParseBytes("24 B"))
+	assert.Equal(t, "1:02:03", FormatDuration(3723*time.Second))
+	assert.Equal(t, 3723*time.Second, ParseDuration("1:02:03"))
+	assert.Equal(t, 5*time.Second, ParseDuration("0:05"))
+}
+
+func TestExtractParentSnapshotID(t *testing.T) {
+	snapshot := `{"time":"2024-01-02T03:04:05Z","parent":"c65d93101a2b","tree":"a1b2","paths":["/home"],"hostname":"host"}`
+	assert.Equal(t, "c65d93101a2b", ExtractParentSnapshotID(snapshot))
+	assert.Equal(t, "", ExtractParentSnapshotID(`{"time":"2024-01-02T03:04:05Z","tree":"a1b2"}`))
+	assert.Equal(t, "", ExtractParentSnapshotID("not json"))
+}
+
+func TestFormatBackupLog(t *testing.T) {
+	formatted := FormatBackupLog(jsonLog)
+	assert.Equal(t, `Files:           1 new,     2 changed,     3 unmodified
+Dirs:            4 new,     5 changed,     6 unmodified
+Added to the repository: 2.000 KiB
+
+processed 6 files, 1.500 MiB in 1:02
+snapshot 917c7691 saved
+`, formatted)
+	// The text log reads the same as the JSON one
+	assert.Equal(t, ExtractMetadataFromBackupLog(jsonLog).Files, ExtractMetadataFromBackupLog(formatted).Files)
+
+	assert.Equal(t, "", FormatBackupLog(`{"message_type":"status","seconds_elapsed":3,"files_done":7,"bytes_done":99}`))
+	assert.Equal(t, textLog, FormatBackupLog(textLog))
+}
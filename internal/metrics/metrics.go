@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cupcakearmy/autorestic/internal/history"
+)
+
+const PREFIX = "autorestic_"
+
+var operations = []string{history.OperationBackup, history.OperationForget, history.OperationCheck}
+
+type series struct {
+	operation string
+	location  string
+	backend   string
+}
+
+type state struct {
+	last        history.Run
+	lastSuccess *history.Run
+}
+
+type gauge struct {
+	name       string
+	help       string
+	backupOnly bool
+	value      func(s state) (float64, bool)
+}
+
+var gauges = []gauge{
+	{"last_run_timestamp_seconds", "Unix time of the last %s.", false, func(s state) (float64, bool) {
+		return float64(s.last.End.Unix()), true
+	}},
+	{"last_success_timestamp_seconds", "Unix time of the last successful %s.", false, func(s state) (float64, bool) {
+		if s.lastSuccess == nil {
+			return 0, false
+		}
+		return float64(s.lastSuccess.End.Unix()), true
+	}},
+	{"success", "Whether the last %s was successful.", false, func(s state) (float64, bool) {
+		if s.last.Success() {
+			return 1, true
+		}
+		return 0, true
+	}},
+	{"exit_code", "Exit code of restic for the last %s.", false, func(s state) (float64, bool) {
+		return float64(s.last.ExitCode), true
+	}},
+	{"duration_seconds", "Duration of the last %s.", false, func(s state) (float64, bool) {
+		return s.last.Duration().Seconds(), true
+	}},
+	{"added_bytes", "Bytes added to the repository by the last %s.", true, func(s state) (float64, bool) {
+		return float64(s.last.AddedBytes), true
+	}},
+	{"files_new", "New files in the last %s.", true, func(s state) (float64, bool) {
+		return float64(s.last.FilesNew), true
+	}},
+	{"files_changed", "Changed files in the last %s.", true, func(s state) (float64, bool) {
+		return float64(s.last.FilesChanged), true
+	}},
+	{"files_unmodified", "Unmodified files in the last %s.", true, func(s state) (float64, bool) {
+		return float64(s.last.FilesUnmodified), true
+	}},
+}
+
+// Collector keeps only the latest state of every location, backend and operation, so that the history is not needed to write the metrics.
+type Collector struct {
+	states map[series]*state
+}
+
+func NewCollector() *Collector {
+	return &Collector{states: make(map[series]*state)}
+}
+
+// Add records runs in the order they happened.
+func (c *Collector) Add(runs ...history.Run) {
+	for _, run := range runs {
+		key := series{run.Operation, run.Location, run.Backend}
+		s, ok := c.states[key]
+		if !ok {
+			s = &state{}
+			c.states[key] = s
+		}
+		s.last = run
+		if run.Success() {
+			success := run
+			s.lastSuccess = &success
+		}
+	}
+}
+
+// Write prints the metrics of the runs in the prometheus text format.
+func Write(w io.Writer, runs []history.Run) error {
+	c := NewCollector()
+	c.Add(runs...)
+	return c.Write(w)
+}
+
+// WriteTextfile writes the metrics of the runs to a file.
+func WriteTextfile(file string, runs []history.Run) error {
+	c := NewCollector()
+	c.Add(runs...)
+	return c.WriteTextfile(file)
+}
+
+// Write prints the metrics in the prometheus text format.
+func (c *Collector) Write(w io.Writer) error {
+	states := c.states
+	keys := make([]series, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.location != b.location {
+			return a.location < b.location
+		}
+		return a.backend < b.backend
+	})
+
+	for _, operation := range operations {
+		for _, g := range gauges {
+			if g.backupOnly && operation != history.OperationBackup {
+				continue
+			}
+			var lines []string
+			for _, key := range keys {
+				if key.operation != operation {
+					continue
+				}
+				if value, ok := g.value(*states[key]); ok {
+					lines = append(lines, fmt.Sprintf("%s{%s} %s", PREFIX+operation+"_"+g.name, labels(key), strconv.FormatFloat(value, 'f', -1, 64)))
+				}
+			}
+			if len(lines) == 0 {
+				continue
+			}
+			name := PREFIX + operation + "_" + g.name
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s\n", name, fmt.Sprintf(g.help, operation), name, strings.Join(lines, "\n")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTextfile writes the metrics atomically, so that the textfile collector never reads a partial file.
+func (c *Collector) WriteTextfile(file string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := c.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func labels(s series) string {
+	var l []string
+	if s.location != "" {
+		l = append(l, fmt.Sprintf(`location="%s"`, escape(s.location)))
+	}
+	l = append(l, fmt.Sprintf(`backend="%s"`, escape(s.backend)))
+	return strings.Join(l, ",")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
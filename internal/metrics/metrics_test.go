@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Unix(1000, 0)
+
+var runs = []history.Run{
+	{Operation: history.OperationBackup, Location: "foo", Backend: "nas", Start: start, End: start.Add(10 * time.Second), AddedBytes: 42, FilesNew: 3},
+	{Operation: history.OperationBackup, Location: "foo", Backend: "nas", Start: start.Add(time.Hour), End: start.Add(time.Hour + time.Second), ExitCode: 1, Error: "boom"},
+	{Operation: history.OperationCheck, Backend: "nas", Start: start, End: start.Add(5 * time.Second)},
+}
+
+func TestWrite(t *testing.T) {
+	var out strings.Builder
+	assert.NoError(t, Write(&out, runs))
+	result := out.String()
+
+	assert.Contains(t, result, "# TYPE autorestic_backup_last_success_timestamp_seconds gauge\n")
+	assert.Contains(t, result, `autorestic_backup_last_success_timestamp_seconds{location="foo",backend="nas"} 1010`)
+	assert.Contains(t, result, `autorestic_backup_last_run_timestamp_seconds{location="foo",backend="nas"} 4601`)
+	assert.Contains(t, result, `autorestic_backup_exit_code{location="foo",backend="nas"} 1`)
+	assert.Contains(t, result, `autorestic_backup_success{location="foo",backend="nas"} 0`)
+	assert.Contains(t, result, `autorestic_backup_added_bytes{location="foo",backend="nas"} 0`)
+	assert.Contains(t, result, `autorestic_check_duration_seconds{backend="nas"} 5`)
+	assert.NotContains(t, result, "autorestic_check_added_bytes")
+	assert.NotContains(t, result, "autorestic_forget_")
+}
+
+func TestWriteTextfile(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "autorestic.prom")
+	assert.NoError(t, WriteTextfile(file, runs))
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "autorestic_backup_success")
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escape("a\"b\\c\nd"))
+}
+
+func TestCollector(t *testing.T) {
+	// Adding the runs one by one gives the same metrics as all at once
+	c := NewCollector()
+	for _, run := range runs {
+		c.Add(run)
+	}
+	var incremental, all strings.Builder
+	assert.NoError(t, c.Write(&incremental))
+	assert.NoError(t, Write(&all, runs))
+	assert.Equal(t, all.String(), incremental.String())
+}
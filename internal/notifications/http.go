@@ -0,0 +1,91 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const httpTimeout = 30 * time.Second
+
+// httpNotifier sends the event to an url.
+// Webhooks send JSON (the whole event by default), plain http notifiers send a text message, e.g. for ntfy.
+type httpNotifier struct {
+	name    string
+	config  Config
+	body    *template.Template
+	client  *http.Client
+	isJSON  bool
+	content string
+}
+
+func newHTTPNotifier(name string, c Config) (Notifier, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("notification \"%s\" has no \"url\"", name)
+	}
+	n := &httpNotifier{
+		name:   name,
+		config: c,
+		client: &http.Client{Timeout: httpTimeout},
+		isJSON: c.Type == TypeWebhook,
+	}
+	if n.config.Method == "" {
+		n.config.Method = http.MethodPost
+	}
+	body := c.Body
+	if body == "" && !n.isJSON {
+		body = defaultMessage
+	}
+	if body != "" {
+		t, err := parseTemplate(name, body)
+		if err != nil {
+			return nil, fmt.Errorf("notification \"%s\" has an invalid body: %w", name, err)
+		}
+		n.body = t
+	}
+	return n, nil
+}
+
+func (n *httpNotifier) Send(event Event) error {
+	var body string
+	if n.body == nil {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		body = string(b)
+	} else {
+		var err error
+		if body, err = render(n.body, event); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(n.config.Method, n.config.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if n.isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	for key, value := range n.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("notification \"%s\" failed with status %s: %s", n.name, res.Status, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/history"
+)
+
+const (
+	TypeWebhook = "webhook"
+	TypeHTTP    = "http"
+	TypeSMTP    = "smtp"
+)
+
+const (
+	OnSuccess = "success"
+	OnFailure = "failure"
+)
+
+// Config of a named notifier as specified in the "notifications" section.
+type Config struct {
+	Type     string            `mapstructure:"type" yaml:"type" json:"type"`
+	URL      string            `mapstructure:"url,omitempty" yaml:"url,omitempty" json:"url,omitempty"`
+	Method   string            `mapstructure:"method,omitempty" yaml:"method,omitempty" json:"method,omitempty"`
+	Headers  map[string]string `mapstructure:"headers,omitempty" yaml:"headers,omitempty" json:"headers,omitempty"`
+	Body     string            `mapstructure:"body,omitempty" yaml:"body,omitempty" json:"body,omitempty"`
+	Host     string            `mapstructure:"host,omitempty" yaml:"host,omitempty" json:"host,omitempty"`
+	Port     int               `mapstructure:"port,omitempty" yaml:"port,omitempty" json:"port,omitempty"`
+	User     string            `mapstructure:"user,omitempty" yaml:"user,omitempty" json:"user,omitempty"`
+	Password string            `mapstructure:"password,omitempty" yaml:"password,omitempty" json:"password,omitempty"`
+	From     string            `mapstructure:"from,omitempty" yaml:"from,omitempty" json:"from,omitempty"`
+	To       []string          `mapstructure:"to,omitempty" yaml:"to,omitempty" json:"to,omitempty"`
+	Subject  string            `mapstructure:"subject,omitempty" yaml:"subject,omitempty" json:"subject,omitempty"`
+}
+
+// Event is passed to every notifier and available in templates.
+type Event struct {
+	Location string        `json:"location"`
+	Status   string        `json:"status"`
+	Success  bool          `json:"success"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Runs     []history.Run `json:"runs"`
+	Errors   []string      `json:"errors"`
+}
+
+func NewEvent(location string, start time.Time, runs []history.Run, errs []error) Event {
+	event := Event{
+		Location: location,
+		Status:   OnSuccess,
+		Success:  len(errs) == 0,
+		Start:    start,
+		End:      time.Now(),
+		Runs:     runs,
+		Errors:   []string{},
+	}
+	if event.Runs == nil {
+		event.Runs = []history.Run{}
+	}
+	if !event.Success {
+		event.Status = OnFailure
+	}
+	for _, err := range errs {
+		event.Errors = append(event.Errors, err.Error())
+	}
+	return event
+}
+
+type Notifier interface {
+	Send(event Event) error
+}
+
+func New(name string, c Config) (Notifier, error) {
+	switch c.Type {
+	case TypeWebhook, TypeHTTP:
+		return newHTTPNotifier(name, c)
+	case TypeSMTP:
+		return newSMTPNotifier(name, c)
+	default:
+		return nil, fmt.Errorf("notification \"%s\" has an invalid type \"%s\"", name, c.Type)
+	}
+}
+
+// ValidateOn checks the events a location subscribes to.
+func ValidateOn(on []string) error {
+	for _, o := range on {
+		if o != OnSuccess && o != OnFailure {
+			return fmt.Errorf("invalid notification event \"%s\", must be \"%s\" or \"%s\"", o, OnSuccess, OnFailure)
+		}
+	}
+	return nil
+}
+
+// ShouldNotify returns whether the event matches the subscribed events. Without any only failures are sent.
+func ShouldNotify(on []string, event Event) bool {
+	if len(on) == 0 {
+		return !event.Success
+	}
+	for _, o := range on {
+		if o == event.Status {
+			return true
+		}
+	}
+	return false
+}
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+func render(t *template.Template, event Event) (string, error) {
+	var out bytes.Buffer
+	if err := t.Execute(&out, event); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+const defaultSubject = `autorestic: backup of "{{ .Location }}" {{ if .Success }}succeeded{{ else }}failed{{ end }}`
+
+const defaultMessage = `Backup of location "{{ .Location }}" {{ if .Success }}succeeded{{ else }}failed{{ end }}.
+{{ range .Runs }}
+{{ .Backend }}: {{ if .Success }}snapshot {{ .SnapshotID }}, {{ .FilesNew }} new, {{ .FilesChanged }} changed, {{ .FilesUnmodified }} unmodified files{{ else }}failed with exit code {{ .ExitCode }}{{ end }}{{ end }}
+{{ range .Errors }}
+{{ . }}
+{{ end }}`
@@ -0,0 +1,198 @@
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent(errs ...error) Event {
+	start := time.Unix(1000, 0)
+	runs := []history.Run{{Operation: history.OperationBackup, Location: "foo", Backend: "nas", Start: start, End: start, SnapshotID: "abc", FilesNew: 2}}
+	return NewEvent("foo", start, runs, errs)
+}
+
+type request struct {
+	header http.Header
+	body   string
+}
+
+func startServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header, string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestNewEvent(t *testing.T) {
+	event := testEvent()
+	assert.True(t, event.Success)
+	assert.Equal(t, OnSuccess, event.Status)
+
+	event = testEvent(errors.New("boom"))
+	assert.False(t, event.Success)
+	assert.Equal(t, OnFailure, event.Status)
+	assert.Equal(t, []string{"boom"}, event.Errors)
+}
+
+func TestShouldNotify(t *testing.T) {
+	success := testEvent()
+	failure := testEvent(errors.New("boom"))
+
+	assert.False(t, ShouldNotify(nil, success))
+	assert.True(t, ShouldNotify(nil, failure))
+	assert.True(t, ShouldNotify([]string{OnSuccess}, success))
+	assert.False(t, ShouldNotify([]string{OnSuccess}, failure))
+	assert.True(t, ShouldNotify([]string{OnFailure, OnSuccess}, failure))
+
+	assert.NoError(t, ValidateOn([]string{OnFailure, OnSuccess}))
+	assert.Error(t, ValidateOn([]string{"always"}))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("foo", Config{Type: "pigeon"})
+	assert.EqualError(t, err, `notification "foo" has an invalid type "pigeon"`)
+
+	_, err = New("foo", Config{Type: TypeWebhook})
+	assert.EqualError(t, err, `notification "foo" has no "url"`)
+
+	_, err = New("foo", Config{Type: TypeSMTP, Host: "localhost", From: "a@example.com"})
+	assert.EqualError(t, err, `notification "foo" has no "to"`)
+
+	_, err = New("foo", Config{Type: TypeHTTP, URL: "http://localhost", Body: "{{ .Nope"})
+	assert.Error(t, err)
+}
+
+func TestWebhook(t *testing.T) {
+	t.Run("default body", func(t *testing.T) {
+		server, requests := startServer(t, http.StatusOK)
+		n, err := New("hook", Config{Type: TypeWebhook, URL: server.URL, Headers: map[string]string{"X-Token": "secret"}})
+		assert.NoError(t, err)
+		assert.NoError(t, n.Send(testEvent()))
+
+		req := <-requests
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		assert.Equal(t, "secret", req.header.Get("X-Token"))
+		var event Event
+		assert.NoError(t, json.Unmarshal([]byte(req.body), &event))
+		assert.Equal(t, "foo", event.Location)
+		assert.Equal(t, "abc", event.Runs[0].SnapshotID)
+	})
+
+	t.Run("templated body", func(t *testing.T) {
+		server, requests := startServer(t, http.StatusOK)
+		n, err := New("hook", Config{Type: TypeWebhook, URL: server.URL, Body: `{"text": {{ json .Location }}, "errors": {{ json .Errors }}}`})
+		assert.NoError(t, err)
+		assert.NoError(t, n.Send(testEvent(errors.New(`"quoted"`))))
+
+		req := <-requests
+		assert.JSONEq(t, `{"text": "foo", "errors": ["\"quoted\""]}`, req.body)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		server, _ := startServer(t, http.StatusInternalServerError)
+		n, err := New("hook", Config{Type: TypeWebhook, URL: server.URL})
+		assert.NoError(t, err)
+		assert.ErrorContains(t, n.Send(testEvent()), "500")
+	})
+}
+
+func TestHTTP(t *testing.T) {
+	server, requests := startServer(t, http.StatusOK)
+	n, err := New("ntfy", Config{Type: TypeHTTP, URL: server.URL, Headers: map[string]string{"Title": "Backup"}})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Send(testEvent(errors.New("boom"))))
+
+	req := <-requests
+	assert.Equal(t, "Backup", req.header.Get("Title"))
+	assert.Contains(t, req.header.Get("Content-Type"), "text/plain")
+	assert.Contains(t, req.body, `Backup of location "foo" failed.`)
+	assert.Contains(t, req.body, "boom")
+}
+
+// startSMTPServer accepts a single mail and returns the raw message.
+func startSMTPServer(t *testing.T) (int, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	messages := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		write("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					messages <- data.String()
+					write("250 OK")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				write("354 Go ahead")
+			case cmd == "QUIT":
+				write("221 Bye")
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestSMTP(t *testing.T) {
+	port, messages := startSMTPServer(t)
+	n, err := New("mail", Config{
+		Type: TypeSMTP,
+		Host: "127.0.0.1",
+		Port: port,
+		From: "backup@example.com",
+		To:   []string{"admin@example.com"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, n.Send(testEvent(errors.New("boom"))))
+
+	message := <-messages
+	assert.Contains(t, message, "To: admin@example.com\r\n")
+	assert.Contains(t, message, `Subject: autorestic: backup of "foo" failed`)
+	assert.Contains(t, message, "boom")
+}
+
+func TestToCRLF(t *testing.T) {
+	assert.Equal(t, "a\r\nb\r\nc\r\nd", toCRLF("a\r\nb\nc\rd"))
+	assert.Equal(t, "a b c d", subjectReplacer.Replace("a\r\nb\nc\rd"))
+}
@@ -0,0 +1,92 @@
+package notifications
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultSMTPPort = 587
+
+type smtpNotifier struct {
+	name    string
+	config  Config
+	subject *template.Template
+	body    *template.Template
+}
+
+func newSMTPNotifier(name string, c Config) (Notifier, error) {
+	if c.Host == "" {
+		return nil, fmt.Errorf("notification \"%s\" has no \"host\"", name)
+	}
+	if c.From == "" {
+		return nil, fmt.Errorf("notification \"%s\" has no \"from\"", name)
+	}
+	if len(c.To) == 0 {
+		return nil, fmt.Errorf("notification \"%s\" has no \"to\"", name)
+	}
+	if c.Port == 0 {
+		c.Port = defaultSMTPPort
+	}
+	if c.Subject == "" {
+		c.Subject = defaultSubject
+	}
+	if c.Body == "" {
+		c.Body = defaultMessage
+	}
+	subject, err := parseTemplate(name, c.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("notification \"%s\" has an invalid subject: %w", name, err)
+	}
+	body, err := parseTemplate(name, c.Body)
+	if err != nil {
+		return nil, fmt.Errorf("notification \"%s\" has an invalid body: %w", name, err)
+	}
+	return &smtpNotifier{name: name, config: c, subject: subject, body: body}, nil
+}
+
+// A subject has to fit on a single header line
+var subjectReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// toCRLF converts all line endings to the CRLF required by SMTP.
+// A lone carriage return, e.g. of the progress output of restic, also ends a line.
+func toCRLF(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
+
+func (n *smtpNotifier) Send(event Event) error {
+	subject, err := render(n.subject, event)
+	if err != nil {
+		return err
+	}
+	body, err := render(n.body, event)
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + n.config.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(n.config.To, ", ") + "\r\n")
+	msg.WriteString("Subject: " + subjectReplacer.Replace(subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(toCRLF(body))
+
+	var auth smtp.Auth
+	if n.config.User != "" {
+		auth = smtp.PlainAuth("", n.config.User, n.config.Password, n.config.Host)
+	}
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	if err := smtp.SendMail(addr, auth, n.config.From, n.config.To, []byte(msg.String())); err != nil {
+		return fmt.Errorf("notification \"%s\" failed: %w", n.name, err)
+	}
+	return nil
+}
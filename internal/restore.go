@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/cupcakearmy/autorestic/internal/metadata"
+	"github.com/fatih/color"
+)
+
+type RestoreOptions struct {
+	// Target directory
+	To string
+	// Backend to restore from, defaults to the first target of the location
+	From string
+	// Snapshot id, defaults to latest
+	Snapshot string
+	// Restore into a target that is not empty
+	Force bool
+	// Only report what would be restored
+	DryRun bool
+	// Compare the restored files with the snapshot
+	Verify bool
+	// Volume to restore a volume location into instead of its own, created if missing
+	ToVolume string
+	// Passed on to restic
+	Args []string
+}
+
+// RestoreItem is a file that is restored, as reported by a dry run.
+type RestoreItem struct {
+	Action string `json:"action"`
+	Path   string `json:"item"`
+	Size   int64  `json:"size"`
+}
+
+// RestorePlan is the result of a dry run.
+type RestorePlan struct {
+	Items      []RestoreItem `json:"items"`
+	TotalFiles int64         `json:"total_files"`
+	TotalBytes int64         `json:"total_bytes"`
+}
+
+type resticRestoreMessage struct {
+	MessageType string `json:"message_type"`
+	RestoreItem
+	TotalFiles int64 `json:"total_files"`
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// parseRestoreDryRun decodes the output of "restic restore --dry-run --json --verbose".
+func parseRestoreDryRun(out string) (RestorePlan, error) {
+	var plan RestorePlan
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var msg resticRestoreMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return plan, fmt.Errorf("could not parse restore output: %w", err)
+		}
+		switch msg.MessageType {
+		case "verbose_status":
+			plan.Items = append(plan.Items, msg.RestoreItem)
+		case "summary":
+			plan.TotalFiles = msg.TotalFiles
+			plan.TotalBytes = msg.TotalBytes
+			found = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return plan, err
+	}
+	if !found {
+		return plan, fmt.Errorf("restic did not report a summary, dry runs need restic 0.17 or newer")
+	}
+	return plan, nil
+}
+
+var verifiedFilesRegex = regexp.MustCompile(`finished verifying (\d+) files`)
+
+// parseVerifiedFiles returns the number of files verified by "restic restore --verify".
+func parseVerifiedFiles(out string) (int64, bool) {
+	match := verifiedFilesRegex.FindStringSubmatch(out)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	return n, err == nil
+}
+
+func buildRestoreCommand(l Location, to string, snapshot string, options []string) []string {
+	base := []string{"restore", "--target", to, "--tag", l.getLocationTags(), snapshot}
+	base = append(base, options...)
+	return base
+}
+
+// selectBackend returns the given backend of the location, or its first target if none is given.
+func (l Location) selectBackend(name string) (Backend, error) {
+	if name == "" {
+		name = l.To[0]
+	} else if !l.hasBackend(name) {
+		return Backend{}, fmt.Errorf("invalid backend: \"%s\"", name)
+	}
+	backend, _ := GetBackend(name)
+	return backend, nil
+}
+
+func (l Location) Restore(options RestoreOptions) error {
+	backend, err := l.selectBackend(options.From)
+	if err != nil {
+		return err
+	}
+
+	snapshot := options.Snapshot
+	if snapshot == "" {
+		snapshot = "latest"
+	}
+
+	colors.PrimaryPrint("Restoring location \"%s\"", l.name)
+	events.Emit(events.Event{Type: events.BackendStarted, Operation: history.OperationRestore, Location: l.name, Backend: backend.name})
+
+	t, err := l.getType()
+	if err != nil {
+		return err
+	}
+	if options.ToVolume != "" && t != TypeVolume {
+		return fmt.Errorf("location \"%s\" is not a volume, --to-volume can only be used for volume locations", l.name)
+	}
+	// Directory or volume that is restored into
+	var target string
+	switch t {
+	case TypeLocal:
+		to, err := filepath.Abs(options.To)
+		if err != nil {
+			return err
+		}
+		// Check if target is empty
+		if !options.Force && !options.DryRun {
+			notEmptyError := fmt.Errorf("target %s is not empty", to)
+			_, err = os.Stat(to)
+			if err == nil {
+				files, err := ioutil.ReadDir(to)
+				if err != nil {
+					return err
+				}
+				if len(files) > 0 {
+					return notEmptyError
+				}
+			} else {
+				if !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		target = to
+	case TypeVolume:
+		target = l.From[0]
+		if options.ToVolume != "" {
+			target = options.ToVolume
+			// Nothing is written by a dry run, so the volume is not needed
+			if !options.DryRun && !CheckIfVolumeExists(target) {
+				colors.Secondary.Printf("Creating volume \"%s\"\n", target)
+				if err := CreateVolume(target); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if options.DryRun {
+		colors.Secondary.Printf("Dry run of restoring %s@%s → %s\n", snapshot, backend.name, target)
+	} else {
+		colors.Secondary.Printf("Restoring %s@%s → %s\n", snapshot, backend.name, target)
+	}
+
+	// Hooks are not run for dry runs, as nothing is written
+	if options.DryRun || l.Hooks.Restore.isEmpty() {
+		if err := l.restore(backend, t, target, snapshot, options); err != nil {
+			return err
+		}
+		if !options.DryRun {
+			colors.Success.Println("Done")
+		}
+		return nil
+	}
+
+	// Resolve the snapshot so that the hooks and restic see the same one
+	s, err := l.resolveSnapshot(backend, snapshot)
+	if err != nil {
+		return err
+	}
+	snapshot = s.ID
+
+	var errs []error
+	cwd, _ := GetPathRelativeToConfig(".")
+	hookOptions := ExecuteOptions{
+		Command: "bash",
+		Dir:     cwd,
+		Envs: map[string]string{
+			"AUTORESTIC_LOCATION":       l.name,
+			"AUTORESTIC_SNAPSHOT_ID":    snapshot,
+			"AUTORESTIC_RESTORE_TARGET": target,
+		},
+	}
+
+	// The restore and after hooks are skipped if the before hooks fail
+	if err := l.ExecuteHooks(l.Hooks.Restore.Before, hookOptions); err != nil {
+		errs = append(errs, err)
+	} else {
+		if err := l.restore(backend, t, target, snapshot, options); err != nil {
+			errs = append(errs, err)
+		}
+		if err := l.ExecuteHooks(l.Hooks.Restore.After, hookOptions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Success/failure hooks
+	commands := l.Hooks.Restore.Success
+	if len(errs) > 0 {
+		commands = l.Hooks.Restore.Failure
+	}
+	if err := l.ExecuteHooks(commands, hookOptions); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	colors.Success.Println("Done")
+	return nil
+}
+
+// restore runs restic restore for the location into the target directory or volume and reports the result of dry runs and verification.
+func (l Location) restore(backend Backend, t LocationType, target string, snapshot string, options RestoreOptions) error {
+	args := options.Args
+	if options.DryRun {
+		args = append(args, "--dry-run", "--json", "--verbose")
+	} else if options.Verify {
+		args = append(args, "--verify")
+	}
+
+	var out string
+	var err error
+	switch t {
+	case TypeLocal:
+		out, err = backend.exec(buildRestoreCommand(l, target, snapshot, args))
+	case TypeVolume:
+		// Mount the target volume in place of the one of the location.
+		// Dry runs keep the volume of the location, as docker would create a missing target.
+		mounted := l
+		if !options.DryRun {
+			mounted.From = []string{target}
+		}
+		_, out, err = backend.ExecDocker(mounted, buildRestoreCommand(l, "/", snapshot, args))
+	}
+	if err != nil {
+		colors.Error.Println(out)
+		return err
+	}
+
+	if options.DryRun {
+		plan, err := parseRestoreDryRun(out)
+		if err != nil {
+			return err
+		}
+		if events.Enabled() {
+			events.Emit(events.Event{Type: events.RestorePlan, Location: l.name, Backend: backend.name, Data: plan})
+			return nil
+		}
+		w := tabwriter.NewWriter(color.Output, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTION\tSIZE\tPATH")
+		for _, item := range plan.Items {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", item.Action, metadata.FormatBytes(item.Size), item.Path)
+		}
+		w.Flush()
+		colors.Success.Printf("Would restore %d files (%s), nothing was written\n", plan.TotalFiles, metadata.FormatBytes(plan.TotalBytes))
+		return nil
+	}
+	if options.Verify {
+		if n, ok := parseVerifiedFiles(out); ok {
+			colors.Success.Printf("Verified %d restored files against the snapshot\n", n)
+		} else {
+			colors.Success.Println("Verified the restored files against the snapshot")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cupcakearmy/autorestic/internal/events"
+	"github.com/cupcakearmy/autorestic/internal/flags"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRestoreDryRun(t *testing.T) {
+	t.Run("restic 0.17", func(t *testing.T) {
+		out := `{"message_type":"verbose_status","action":"restored","item":"/restore/data/a.txt","size":1024}
+{"message_type":"verbose_status","action":"updated","item":"/restore/data/b.txt","size":2048}
+{"message_type":"status","seconds_elapsed":0,"percent_done":1}
+{"message_type":"summary","seconds_elapsed":0,"total_files":2,"files_restored":2,"files_skipped":0,"total_bytes":3072,"bytes_restored":3072,"bytes_skipped":0}
+`
+		result, err := parseRestoreDryRun(out)
+		assert.NoError(t, err)
+		assert.Equal(t, []RestoreItem{
+			{Action: "restored", Path: "/restore/data/a.txt", Size: 1024},
+			{Action: "updated", Path: "/restore/data/b.txt", Size: 2048},
+		}, result.Items)
+		assert.Equal(t, int64(2), result.TotalFiles)
+		assert.Equal(t, int64(3072), result.TotalBytes)
+	})
+
+	t.Run("older restic", func(t *testing.T) {
+		_, err := parseRestoreDryRun("restoring <Snapshot 917c7691 of [/data]> to /restore\n")
+		assert.Error(t, err)
+	})
+}
+
+func TestParseVerifiedFiles(t *testing.T) {
+	out := "restoring <Snapshot 917c7691 of [/data]> to /restore\nverifying files in /restore\nfinished verifying 42 files in /restore (took 0.01s)\n"
+	n, ok := parseVerifiedFiles(out)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), n)
+
+	_, ok = parseVerifiedFiles("restoring <Snapshot 917c7691 of [/data]> to /restore\n")
+	assert.False(t, ok)
+}
+
+func TestRestoreHooksIsEmpty(t *testing.T) {
+	assert.True(t, RestoreHooks{}.isEmpty())
+	assert.False(t, RestoreHooks{Before: []string{"systemctl stop db"}}.isEmpty())
+	assert.False(t, RestoreHooks{Failure: []string{"echo failed"}}.isEmpty())
+}
+
+func TestRestoreDryRunEvents(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		".autorestic.yml": `version: 2
+backends:
+  hdd: {type: local, path: hdd, key: secret}
+locations:
+  foo: {from: data, to: hdd}
+`,
+		"restic": `#!/bin/sh
+echo '{"message_type":"verbose_status","action":"restored","item":"/restore/data/a.txt","size":1024}'
+echo '{"message_type":"summary","total_files":1,"total_bytes":1024}'
+`,
+	})
+	t.Cleanup(viper.Reset)
+	assert.NoError(t, os.Chmod(path.Join(dir, "restic"), 0755))
+	bin, output := flags.RESTIC_BIN, flags.OUTPUT
+	flags.RESTIC_BIN, flags.OUTPUT = path.Join(dir, "restic"), flags.OutputJSON
+	t.Cleanup(func() { flags.RESTIC_BIN, flags.OUTPUT = bin, output })
+	ReloadConfig()
+	l, _ := GetLocation("foo")
+	backend, _ := GetBackend("hdd")
+
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	err = l.restore(backend, TypeLocal, path.Join(dir, "restore"), "latest", RestoreOptions{DryRun: true})
+	os.Stdout = stdout
+	w.Close()
+	assert.NoError(t, err)
+	out, _ := io.ReadAll(r)
+
+	var event struct {
+		Type     string      `json:"type"`
+		Location string      `json:"location"`
+		Backend  string      `json:"backend"`
+		Data     RestorePlan `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(out, &event))
+	assert.Equal(t, events.RestorePlan, event.Type)
+	assert.Equal(t, "foo", event.Location)
+	assert.Equal(t, "hdd", event.Backend)
+	assert.Equal(t, RestorePlan{
+		Items:      []RestoreItem{{Action: "restored", Path: "/restore/data/a.txt", Size: 1024}},
+		TotalFiles: 1,
+		TotalBytes: 1024,
+	}, event.Data)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cupcakearmy/autorestic/internal/colors"
+	"github.com/cupcakearmy/autorestic/internal/history"
+	"github.com/cupcakearmy/autorestic/internal/metrics"
+)
+
+var saveMutex sync.Mutex
+
+// saveRun finishes the run, appends it to the history and updates the metrics file if one is configured.
+func saveRun(run history.Run, out string, err error) history.Run {
+	run.End = time.Now()
+	if err != nil {
+		// On failure the output contains the error message of restic
+		run.Error = strings.TrimSpace(out + "\n" + err.Error())
+		if run.ExitCode == 0 {
+			run.ExitCode = -1
+		}
+	}
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+	if err := history.Append(run); err != nil {
+		colors.Error.Println("Could not save history:", err)
+	}
+	if err := writeMetrics(); err != nil {
+		colors.Error.Println("Could not write metrics:", err)
+	}
+	return run
+}
+
+// The metrics only need the latest run of every location, backend and operation,
+// so the history is read once and then only the runs appended since, including the ones of other instances.
+var metricsCollector = metrics.NewCollector()
+var metricsHistory history.Reader
+
+func writeMetrics() error {
+	textfile := GetConfig().Global.Metrics.Textfile
+	if textfile == "" {
+		return nil
+	}
+	file, err := GetPathRelativeToConfig(textfile)
+	if err != nil {
+		return err
+	}
+	runs, reset, err := metricsHistory.Next()
+	if err != nil {
+		return err
+	}
+	if reset {
+		metricsCollector = metrics.NewCollector()
+	}
+	metricsCollector.Add(runs...)
+	return metricsCollector.WriteTextfile(file)
+}
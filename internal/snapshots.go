@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Snapshot of a location as listed by restic, together with the backend it is stored in.
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags,omitempty"`
+	Location string    `json:"location"`
+	Backend  string    `json:"backend"`
+	// Size of the backed up data, only reported by restic 0.17 and newer
+	Size int64 `json:"size,omitempty"`
+}
+
+type resticSnapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags"`
+	Summary  *struct {
+		TotalBytesProcessed int64 `json:"total_bytes_processed"`
+	} `json:"summary"`
+}
+
+// parseSnapshots decodes the output of "restic snapshots --json", oldest first.
+func parseSnapshots(data string, location, backend string) ([]Snapshot, error) {
+	var parsed []resticSnapshot
+	if err := json.Unmarshal([]byte(data), &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse snapshots of backend \"%s\": %w", backend, err)
+	}
+	snapshots := make([]Snapshot, 0, len(parsed))
+	for _, s := range parsed {
+		snapshot := Snapshot{
+			ID:       s.ID,
+			ShortID:  s.ShortID,
+			Time:     s.Time,
+			Hostname: s.Hostname,
+			Paths:    s.Paths,
+			Tags:     s.Tags,
+			Location: location,
+			Backend:  backend,
+		}
+		if s.Summary != nil {
+			snapshot.Size = s.Summary.TotalBytesProcessed
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	// Snapshots are picked by position, the order of restic cannot be relied on
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// getSnapshots lists the snapshots of the location in a single backend, oldest first.
+func (l Location) getSnapshots(backend Backend) ([]Snapshot, error) {
+	env, err := backend.getEnv()
+	if err != nil {
+		return nil, err
+	}
+	cmd := []string{"snapshots", "--json", "--tag", l.getLocationTags()}
+	cmd = append(cmd, combineBackendOptions("snapshots", backend)...)
+	_, out, err := ExecuteResticCommand(ExecuteOptions{Envs: env, Silent: true}, cmd...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshots of backend \"%s\": %s%w", backend.name, out, err)
+	}
+	return parseSnapshots(out, l.name, backend.name)
+}
+
+// Snapshots lists the snapshots of the location across all its backends, including copy targets, sorted by time.
+// Backends that fail are reported in the error, the snapshots of the other backends are still returned.
+func (l Location) Snapshots() ([]Snapshot, error) {
+	var snapshots []Snapshot
+	var errs []error
+	for _, name := range l.getBackends() {
+		backend, ok := GetBackend(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid backend \"%s\"", name))
+			continue
+		}
+		s, err := l.getSnapshots(backend)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		snapshots = append(snapshots, s...)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, errors.Join(errs...)
+}
+
+// snapshotAt returns the most recent of the snapshots, sorted by time, taken at or before the given time.
+func snapshotAt(snapshots []Snapshot, at time.Time) (Snapshot, bool) {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Time.After(at) {
+			return snapshots[i], true
+		}
+	}
+	return Snapshot{}, false
+}
+
+// SnapshotAt returns the most recent snapshot of the location on a backend taken at or before the given time.
+func (l Location) SnapshotAt(from string, at time.Time) (Snapshot, error) {
+	backend, err := l.selectBackend(from)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshots, err := l.getSnapshots(backend)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot, ok := snapshotAt(snapshots, at)
+	if !ok {
+		return Snapshot{}, fmt.Errorf("location \"%s\" has no snapshot on backend \"%s\" taken at or before %s", l.name, backend.name, at.Format(time.DateTime))
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSnapshots(t *testing.T) {
+	t.Run("with summary", func(t *testing.T) {
+		out := `[{"time":"2026-10-01T03:00:00.123456789+02:00","tree":"a1b2","paths":["/data"],"hostname":"server","username":"root","tags":["ar:location:foo"],"id":"917c7691aaaa","short_id":"917c7691","summary":{"data_added":1024,"total_bytes_processed":2048}}]`
+		result, err := parseSnapshots(out, "foo", "nas")
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "917c7691", result[0].ShortID)
+		assert.Equal(t, "917c7691aaaa", result[0].ID)
+		assert.Equal(t, "server", result[0].Hostname)
+		assert.Equal(t, []string{"/data"}, result[0].Paths)
+		assert.Equal(t, "foo", result[0].Location)
+		assert.Equal(t, "nas", result[0].Backend)
+		assert.Equal(t, int64(2048), result[0].Size)
+		assert.True(t, result[0].Time.Equal(time.Date(2026, 10, 1, 1, 0, 0, 123456789, time.UTC)))
+	})
+
+	t.Run("without summary", func(t *testing.T) {
+		out := `[{"time":"2026-10-01T03:00:00Z","paths":["/data"],"hostname":"server","id":"917c7691aaaa","short_id":"917c7691"}]`
+		result, err := parseSnapshots(out, "foo", "nas")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), result[0].Size)
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		out := `[{"time":"2026-10-02T03:00:00Z","id":"bbbb","short_id":"b"},{"time":"2026-10-03T03:00:00Z","id":"cccc","short_id":"c"},{"time":"2026-10-01T03:00:00Z","id":"aaaa","short_id":"a"}]`
+		result, err := parseSnapshots(out, "foo", "nas")
+		assert.NoError(t, err)
+		assert.Len(t, result, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, []string{result[0].ShortID, result[1].ShortID, result[2].ShortID})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		result, err := parseSnapshots("[]\n", "foo", "nas")
+		assert.NoError(t, err)
+		assert.Len(t, result, 0)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseSnapshots("Fatal: wrong password", "foo", "nas")
+		assert.Error(t, err)
+	})
+}
+
+func TestSnapshotAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 3, 0, 0, 0, time.UTC)
+	}
+	snapshots := []Snapshot{{ID: "a", Time: day(1)}, {ID: "b", Time: day(2)}, {ID: "c", Time: day(3)}}
+
+	t.Run("between snapshots", func(t *testing.T) {
+		result, ok := snapshotAt(snapshots, day(2).Add(time.Hour))
+		assert.True(t, ok)
+		assert.Equal(t, "b", result.ID)
+	})
+
+	t.Run("exact time", func(t *testing.T) {
+		result, ok := snapshotAt(snapshots, day(2))
+		assert.True(t, ok)
+		assert.Equal(t, "b", result.ID)
+	})
+
+	t.Run("after last", func(t *testing.T) {
+		result, ok := snapshotAt(snapshots, day(10))
+		assert.True(t, ok)
+		assert.Equal(t, "c", result.ID)
+	})
+
+	t.Run("before first", func(t *testing.T) {
+		_, ok := snapshotAt(snapshots, day(1).Add(-time.Second))
+		assert.False(t, ok)
+	})
+}
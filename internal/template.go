@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Templates hold shared settings that locations and backends can extend.
+type Templates struct {
+	Locations map[string]Location `mapstructure:"locations,omitempty" yaml:"locations,omitempty" json:"locations,omitempty"`
+	Backends  map[string]Backend  `mapstructure:"backends,omitempty" yaml:"backends,omitempty" json:"backends,omitempty"`
+}
+
+// merge returns the base with the values of the override on top.
+// Structs and maps are merged key by key, all other values are replaced if they are set in the override.
+func merge[T any](base, override T) T {
+	return mergeValue(reflect.ValueOf(base), reflect.ValueOf(override)).Interface().(T)
+}
+
+func mergeValue(base, override reflect.Value) reflect.Value {
+	switch base.Kind() {
+	case reflect.Struct:
+		merged := reflect.New(base.Type()).Elem()
+		merged.Set(override)
+		for i := 0; i < merged.NumField(); i++ {
+			if merged.Field(i).CanSet() {
+				merged.Field(i).Set(mergeValue(base.Field(i), override.Field(i)))
+			}
+		}
+		return merged
+	case reflect.Map:
+		if override.Len() == 0 {
+			return base
+		}
+		if base.Len() == 0 {
+			return override
+		}
+		merged := reflect.MakeMapWithSize(base.Type(), base.Len()+override.Len())
+		for _, key := range base.MapKeys() {
+			merged.SetMapIndex(key, base.MapIndex(key))
+		}
+		for _, key := range override.MapKeys() {
+			value := override.MapIndex(key)
+			if b := base.MapIndex(key); b.IsValid() {
+				value = mergeValue(b, value)
+			}
+			merged.SetMapIndex(key, value)
+		}
+		return merged
+	default:
+		if override.IsZero() {
+			return base
+		}
+		return override
+	}
+}
+
+// rebase returns the value as it is defined with the changes applied that were made to its resolved value since.
+// Everything that did not change is kept as defined, so values that are written explicitly stay even if they equal the template.
+func rebase[T any](defined, resolved, value T) T {
+	return rebaseValue(reflect.ValueOf(defined), reflect.ValueOf(resolved), reflect.ValueOf(value)).Interface().(T)
+}
+
+func rebaseValue(defined, resolved, value reflect.Value) reflect.Value {
+	if reflect.DeepEqual(resolved.Interface(), value.Interface()) {
+		return defined
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		rebased := reflect.New(value.Type()).Elem()
+		rebased.Set(value)
+		for i := 0; i < rebased.NumField(); i++ {
+			if rebased.Field(i).CanSet() {
+				rebased.Field(i).Set(rebaseValue(defined.Field(i), resolved.Field(i), value.Field(i)))
+			}
+		}
+		return rebased
+	case reflect.Map:
+		rebased := reflect.MakeMap(value.Type())
+		for _, key := range value.MapKeys() {
+			v := rebaseValue(mapIndex(defined, key), mapIndex(resolved, key), value.MapIndex(key))
+			if v.IsZero() || (v.Kind() == reflect.Map && v.Len() == 0) {
+				continue
+			}
+			rebased.SetMapIndex(key, v)
+		}
+		if rebased.Len() == 0 {
+			return reflect.Zero(value.Type())
+		}
+		return rebased
+	default:
+		return value
+	}
+}
+
+// mapIndex returns the value of a key in a map, or the zero value if it is missing.
+func mapIndex(m, key reflect.Value) reflect.Value {
+	if v := m.MapIndex(key); v.IsValid() {
+		return v
+	}
+	return reflect.Zero(m.Type().Elem())
+}
+
+// extend merges a value into the template it extends, which can extend another template itself.
+func extend[T any](kind string, templates map[string]T, value T, extends func(T) string, chain []string) (T, error) {
+	name := extends(value)
+	if name == "" {
+		return value, nil
+	}
+	for _, c := range chain {
+		if c == name {
+			return value, fmt.Errorf("%s templates extend each other: %s", kind, strings.Join(append(chain, name), " → "))
+		}
+	}
+	template, ok := templates[name]
+	if !ok {
+		return value, fmt.Errorf("%s template \"%s\" does not exist", kind, name)
+	}
+	base, err := extend(kind, templates, template, extends, append(chain, name))
+	if err != nil {
+		return value, err
+	}
+	return merge(base, value), nil
+}
+
+func (c *Config) resolveLocation(l Location) (Location, error) {
+	return extend("location", c.Templates.Locations, l, func(l Location) string { return l.Extends }, nil)
+}
+
+func (c *Config) resolveBackend(b Backend) (Backend, error) {
+	return extend("backend", c.Templates.Backends, b, func(b Backend) string { return b.Extends }, nil)
+}
+
+// resolveTemplates merges the templates into the locations and backends extending them.
+// The entries as they are written in the config are kept to write them back unchanged.
+func (c *Config) resolveTemplates() error {
+	for name, l := range c.Locations {
+		if l.Extends == "" {
+			continue
+		}
+		resolved, err := c.resolveLocation(l)
+		if err != nil {
+			return fmt.Errorf("location \"%s\": %w", name, err)
+		}
+		if c.definedLocations == nil {
+			c.definedLocations = map[string]Location{}
+		}
+		c.definedLocations[name] = l
+		c.Locations[name] = resolved
+	}
+	for name, b := range c.Backends {
+		if b.Extends == "" {
+			continue
+		}
+		resolved, err := c.resolveBackend(b)
+		if err != nil {
+			return fmt.Errorf("backend \"%s\": %w", name, err)
+		}
+		if c.definedBackends == nil {
+			c.definedBackends = map[string]Backend{}
+		}
+		c.definedBackends[name] = b
+		c.Backends[name] = resolved
+	}
+	return nil
+}
+
+// unresolvedLocation returns a location as it has to be written to the config, without the values of its templates.
+// Locations read from the config keep the values they define, only the changes made since are applied to them.
+func (c *Config) unresolvedLocation(name string, l Location) Location {
+	if l.Extends == "" {
+		return l
+	}
+	if defined, ok := c.definedLocations[name]; ok {
+		if resolved, err := c.resolveLocation(defined); err == nil {
+			return rebase(defined, resolved, l)
+		}
+	}
+	return l
+}
+
+// unresolvedBackend returns a backend as it has to be written to the config, without the values of its templates.
+// Backends read from the config keep the values they define, only the changes made since are applied to them.
+func (c *Config) unresolvedBackend(name string, b Backend) Backend {
+	if b.Extends == "" {
+		return b
+	}
+	if defined, ok := c.definedBackends[name]; ok {
+		if resolved, err := c.resolveBackend(defined); err == nil {
+			return rebase(defined, resolved, b)
+		}
+	}
+	return b
+}
+
+// unresolved returns a copy of the config with the locations and backends as they are written in the config.
+func (c *Config) unresolved() Config {
+	u := *c
+	u.Locations = make(map[string]Location, len(c.Locations))
+	for name, l := range c.Locations {
+		u.Locations[name] = c.unresolvedLocation(name, l)
+	}
+	u.Backends = make(map[string]Backend, len(c.Backends))
+	for name, b := range c.Backends {
+		u.Backends[name] = c.unresolvedBackend(name, b)
+	}
+	return u
+}
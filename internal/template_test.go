@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	template := Location{
+		To:   []string{"nas"},
+		Cron: "0 3 * * *",
+		Hooks: Hooks{
+			Before:  HookArray{"echo before"},
+			Failure: HookArray{"echo failure"},
+		},
+		Options: Options{
+			"forget": {"keep-daily": {7}, "keep-weekly": {4}},
+			"backup": {"tag": {"daily"}},
+		},
+		CopyOption: LocationCopy{"nas": {"b2"}},
+	}
+	location := Location{
+		Extends: "daily",
+		From:    []string{"/home"},
+		Cron:    "0 4 * * *",
+		Hooks: Hooks{
+			Before: HookArray{"echo home"},
+		},
+		Options: Options{
+			"forget": {"keep-daily": {14}},
+		},
+		CopyOption: LocationCopy{"hdd": {"b2"}},
+	}
+
+	merged := merge(template, location)
+	assert.Equal(t, Location{
+		Extends: "daily",
+		From:    []string{"/home"},
+		To:      []string{"nas"},
+		Cron:    "0 4 * * *",
+		Hooks: Hooks{
+			Before:  HookArray{"echo home"},
+			Failure: HookArray{"echo failure"},
+		},
+		Options: Options{
+			"forget": {"keep-daily": {14}, "keep-weekly": {4}},
+			"backup": {"tag": {"daily"}},
+		},
+		CopyOption: LocationCopy{"nas": {"b2"}, "hdd": {"b2"}},
+	}, merged)
+
+	// The template is left untouched
+	assert.Equal(t, Options{"forget": {"keep-daily": {7}, "keep-weekly": {4}}, "backup": {"tag": {"daily"}}}, template.Options)
+}
+
+func TestResolveTemplates(t *testing.T) {
+	c := Config{
+		Templates: Templates{
+			Locations: map[string]Location{
+				"base":  {To: []string{"nas"}},
+				"daily": {Extends: "base", Cron: "0 3 * * *"},
+			},
+			Backends: map[string]Backend{
+				"s3": {Type: "s3", Env: map[string]string{"AWS_ACCESS_KEY_ID": "id"}},
+			},
+		},
+		Locations: map[string]Location{
+			"home": {Extends: "daily", From: []string{"/home"}},
+			"etc":  {From: []string{"/etc"}, To: []string{"hdd"}},
+		},
+		Backends: map[string]Backend{
+			"bucket": {Extends: "s3", Path: "s3.amazonaws.com/bucket"},
+		},
+	}
+	assert.NoError(t, c.resolveTemplates())
+
+	assert.Equal(t, Location{Extends: "daily", From: []string{"/home"}, To: []string{"nas"}, Cron: "0 3 * * *"}, c.Locations["home"])
+	assert.Equal(t, Location{From: []string{"/etc"}, To: []string{"hdd"}}, c.Locations["etc"])
+	assert.Equal(t, Backend{Extends: "s3", Type: "s3", Path: "s3.amazonaws.com/bucket", Env: map[string]string{"AWS_ACCESS_KEY_ID": "id"}}, c.Backends["bucket"])
+
+	u := c.unresolved()
+	assert.Equal(t, Location{Extends: "daily", From: []string{"/home"}}, u.Locations["home"])
+	assert.Equal(t, Backend{Extends: "s3", Path: "s3.amazonaws.com/bucket"}, u.Backends["bucket"])
+
+	// Changed values are written without the ones of the template
+	bucket := c.Backends["bucket"]
+	bucket.Key = "secret"
+	c.Backends["bucket"] = bucket
+	assert.Equal(t, Backend{Extends: "s3", Path: "s3.amazonaws.com/bucket", Key: "secret"}, c.unresolvedBackend("bucket", bucket))
+}
+
+func TestUnresolvedKeepsDefinedValues(t *testing.T) {
+	c := Config{
+		Templates: Templates{
+			Locations: map[string]Location{"daily": {To: []string{"nas"}, Cron: "0 3 * * *"}},
+			Backends:  map[string]Backend{"s3": {Type: "s3", Env: map[string]string{"AWS_ACCESS_KEY_ID": "id"}}},
+		},
+		Locations: map[string]Location{
+			// The cron equals the one of the template, but is written explicitly
+			"home": {Extends: "daily", From: []string{"/home"}, Cron: "0 3 * * *"},
+		},
+		Backends: map[string]Backend{
+			"bucket": {Extends: "s3", Type: "s3", Env: map[string]string{"AWS_ACCESS_KEY_ID": "id", "AWS_REGION": "eu"}},
+		},
+	}
+	assert.NoError(t, c.resolveTemplates())
+
+	home := c.Locations["home"]
+	home.From = []string{"/home", "/root"}
+	assert.Equal(t, Location{Extends: "daily", From: []string{"/home", "/root"}, Cron: "0 3 * * *"}, c.unresolvedLocation("home", home))
+
+	// Only the changed values are added or removed
+	bucket := c.Backends["bucket"]
+	bucket.Key = "secret"
+	bucket.Env = map[string]string{"AWS_ACCESS_KEY_ID": "id"}
+	assert.Equal(t, Backend{Extends: "s3", Type: "s3", Key: "secret", Env: map[string]string{"AWS_ACCESS_KEY_ID": "id"}}, c.unresolvedBackend("bucket", bucket))
+}
+
+func TestResolveTemplatesErrors(t *testing.T) {
+	c := Config{
+		Templates: Templates{Locations: map[string]Location{
+			"a": {Extends: "b"},
+			"b": {Extends: "a"},
+		}},
+		Locations: map[string]Location{"home": {Extends: "a"}},
+	}
+	assert.EqualError(t, c.resolveTemplates(), `location "home": location templates extend each other: a → b → a`)
+
+	c = Config{Backends: map[string]Backend{"nas": {Extends: "missing"}}}
+	assert.EqualError(t, c.resolveTemplates(), `backend "nas": backend template "missing" does not exist`)
+}
+
+func TestSaveConfigKeepsTemplates(t *testing.T) {
+	config := `version: 2
+templates:
+  backends:
+    local:
+      type: local
+      env:
+        RESTIC_CACHE_DIR: /tmp/cache
+backends:
+  nas:
+    extends: local
+    path: /mnt/nas
+`
+	dir := writeConfigFiles(t, map[string]string{".autorestic.yml": config})
+	c := ReloadConfig()
+	nas := c.Backends["nas"]
+	assert.Equal(t, "local", nas.Type)
+	assert.NoError(t, c.SaveConfig())
+
+	nas.Key = "secret"
+	c.Backends["nas"] = nas
+	assert.NoError(t, c.SaveConfig())
+
+	content, err := os.ReadFile(path.Join(dir, ".autorestic.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, config+"    key: secret\n", string(content))
+}
@@ -26,6 +26,10 @@ type ExecuteOptions struct {
 	Envs    map[string]string
 	Dir     string
 	Silent  bool
+	// Where verbose output is written, defaults to the output of the colors
+	Output io.Writer
+	// Streams the standard output of the command instead of returning it
+	Stdout io.Writer
 }
 
 type ColoredWriter struct {
@@ -48,15 +52,21 @@ func ExecuteCommand(options ExecuteOptions, args ...string) (int, string, error)
 	cmd.Env = env
 	cmd.Dir = options.Dir
 
+	output := options.Output
+	if output == nil {
+		output = color.Output
+	}
 	if flags.VERBOSE {
-		colors.Faint.Printf("> Executing: %s\n", cmd)
+		colors.Faint.Fprintf(output, "> Executing: %s\n", cmd)
 	}
 
 	var out bytes.Buffer
 	var error bytes.Buffer
-	if flags.VERBOSE && !options.Silent {
+	if options.Stdout != nil {
+		cmd.Stdout = options.Stdout
+	} else if flags.VERBOSE && !options.Silent {
 		var colored ColoredWriter = ColoredWriter{
-			target: os.Stdout,
+			target: output,
 			color:  colors.Faint,
 		}
 		mw := io.MultiWriter(colored, &out)
@@ -79,35 +89,24 @@ func ExecuteCommand(options ExecuteOptions, args ...string) (int, string, error)
 func ExecuteResticCommand(options ExecuteOptions, args ...string) (int, string, error) {
 	options.Command = flags.RESTIC_BIN
 	var c = GetConfig()
-	var optionsAsString = getOptions(c.Global, []string{"all"})
+	var optionsAsString = getOptions(c.Global.Options, []string{"all"})
 	args = append(optionsAsString, args...)
 	return ExecuteCommand(options, args...)
 }
 
-func CopyFile(from, to string) error {
-	original, err := os.Open(from)
-	if err != nil {
-		return nil
-	}
-	defer original.Close()
+func CheckIfVolumeExists(volume string) bool {
+	_, _, err := ExecuteCommand(ExecuteOptions{Command: "docker"}, "volume", "inspect", volume)
+	return err == nil
+}
 
-	new, err := os.Create(to)
+func CreateVolume(volume string) error {
+	_, out, err := ExecuteCommand(ExecuteOptions{Command: "docker"}, "volume", "create", volume)
 	if err != nil {
-		return nil
-	}
-	defer new.Close()
-
-	if _, err := io.Copy(new, original); err != nil {
-		return err
+		return fmt.Errorf("could not create volume \"%s\": %s", volume, out)
 	}
 	return nil
 }
 
-func CheckIfVolumeExists(volume string) bool {
-	_, _, err := ExecuteCommand(ExecuteOptions{Command: "docker"}, "volume", "inspect", volume)
-	return err == nil
-}
-
 func ArrayContains[T comparable](arr []T, needle T) bool {
 	for _, item := range arr {
 		if item == needle {
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/cupcakearmy/autorestic/internal/colors"
+)
+
+// LocationVerify configures the check of the backends after a successful backup.
+type LocationVerify struct {
+	// Check the structure of the repository without reading any data
+	Enabled bool `mapstructure:"enabled,omitempty" yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// Read all the data of the repository
+	ReadData bool `mapstructure:"readData,omitempty" yaml:"readData,omitempty" json:"readData,omitempty"`
+	// Read a subset of the data, e.g. "5%", "1/10" or "500M"
+	ReadDataSubset string `mapstructure:"readDataSubset,omitempty" yaml:"readDataSubset,omitempty" json:"readDataSubset,omitempty"`
+}
+
+// Formats accepted by restic check --read-data-subset
+var readDataSubsetRegex = regexp.MustCompile(`^(\d+/\d+|\d+(\.\d+)?%|\d+(\.\d+)?[KMGTkmgt]?)$`)
+
+func (v LocationVerify) isEnabled() bool {
+	return v.Enabled || v.ReadData || v.ReadDataSubset != ""
+}
+
+func (v LocationVerify) validate() error {
+	if v.ReadData && v.ReadDataSubset != "" {
+		return fmt.Errorf(`"readData" and "readDataSubset" cannot be used together`)
+	}
+	if v.ReadDataSubset != "" && !readDataSubsetRegex.MatchString(v.ReadDataSubset) {
+		return fmt.Errorf(`invalid "readDataSubset" value "%s"`, v.ReadDataSubset)
+	}
+	return nil
+}
+
+func (v LocationVerify) buildCommand() []string {
+	cmd := []string{"check"}
+	if v.ReadData {
+		cmd = append(cmd, "--read-data")
+	} else if v.ReadDataSubset != "" {
+		cmd = append(cmd, "--read-data-subset", v.ReadDataSubset)
+	}
+	return cmd
+}
+
+// verifyBackend checks the integrity of the repository of a backend after a backup.
+func (l Location) verifyBackend(backend Backend, output io.Writer) error {
+	colors.Secondary.Fprintln(output, "Verifying "+backend.name)
+	env, err := backend.getEnv()
+	if err != nil {
+		return err
+	}
+	cmd := l.Verify.buildCommand()
+	cmd = append(cmd, combineBackendOptions("check", backend)...)
+	_, out, err := ExecuteResticCommand(ExecuteOptions{
+		Envs:   env,
+		Output: output,
+	}, cmd...)
+	if err != nil {
+		colors.Error.Fprintln(output, out)
+		return fmt.Errorf("%s@%s: verification failed:\n%s%s", l.name, backend.name, out, err)
+	}
+	return nil
+}
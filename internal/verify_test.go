@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationVerify(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		assert.False(t, LocationVerify{}.isEnabled())
+	})
+
+	t.Run("structure only", func(t *testing.T) {
+		v := LocationVerify{Enabled: true}
+		assert.True(t, v.isEnabled())
+		assert.NoError(t, v.validate())
+		assert.Equal(t, []string{"check"}, v.buildCommand())
+	})
+
+	t.Run("read data", func(t *testing.T) {
+		v := LocationVerify{ReadData: true}
+		assert.True(t, v.isEnabled())
+		assert.Equal(t, []string{"check", "--read-data"}, v.buildCommand())
+	})
+
+	t.Run("read data subset", func(t *testing.T) {
+		v := LocationVerify{ReadDataSubset: "5%"}
+		assert.True(t, v.isEnabled())
+		assert.Equal(t, []string{"check", "--read-data-subset", "5%"}, v.buildCommand())
+	})
+
+	t.Run("valid subsets", func(t *testing.T) {
+		for _, subset := range []string{"5%", "2.5%", "1/10", "500M", "1G", "1024"} {
+			assert.NoError(t, LocationVerify{ReadDataSubset: subset}.validate(), subset)
+		}
+	})
+
+	t.Run("invalid subsets", func(t *testing.T) {
+		for _, subset := range []string{"five", "5 %", "1/", "%"} {
+			assert.Error(t, LocationVerify{ReadDataSubset: subset}.validate(), subset)
+		}
+	})
+
+	t.Run("read data and subset", func(t *testing.T) {
+		assert.Error(t, LocationVerify{ReadData: true, ReadDataSubset: "5%"}.validate())
+	})
+}
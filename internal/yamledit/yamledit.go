@@ -0,0 +1,909 @@
+// Package yamledit changes values of YAML documents in place.
+// Only the text of the changed nodes is rewritten, comments, ordering, anchors and formatting of everything else are kept as they are.
+package yamledit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a YAML document whose root is a mapping.
+type Document struct {
+	src []byte
+	// Root mapping, nil for empty documents
+	root *yaml.Node
+	// Byte offsets of the start of every line
+	lines []int
+	// Indentation width used for new nested blocks
+	indent  int
+	newline string
+}
+
+// Parse reads a document from its source.
+func Parse(src []byte) (*Document, error) {
+	d := &Document{src: src}
+	if err := d.parse(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Load reads a document from a file.
+func Load(file string) (*Document, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(src)
+}
+
+// Bytes returns the source of the document.
+func (d *Document) Bytes() []byte {
+	return d.src
+}
+
+// Save atomically replaces the file with the document, keeping its permissions.
+func (d *Document) Save(file string) error {
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(d.src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (d *Document) parse() error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(d.src, &doc); err != nil {
+		return err
+	}
+	d.root = nil
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return fmt.Errorf("the root of the document is not a mapping")
+		}
+		d.root = root
+	}
+
+	d.lines = []int{0}
+	for i, c := range d.src {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	d.newline = "\n"
+	if bytes.Contains(d.src, []byte("\r\n")) {
+		d.newline = "\r\n"
+	}
+	d.indent = detectIndent(d.root)
+	return nil
+}
+
+// detectIndent returns the indentation width of the first nested block mapping, or 2.
+func detectIndent(n *yaml.Node) int {
+	if n == nil || n.Kind != yaml.MappingNode || n.Style&yaml.FlowStyle != 0 {
+		return 2
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 && len(v.Content) > 0 {
+			if diff := v.Content[0].Column - k.Column; diff > 0 {
+				return diff
+			}
+		}
+	}
+	return 2
+}
+
+// Get returns the node at the path of mapping keys.
+func (d *Document) Get(path []string) (*yaml.Node, bool) {
+	_, _, v := d.find(path)
+	return v, v != nil
+}
+
+// find returns the mapping holding the last key of the path together with the key and value nodes.
+func (d *Document) find(path []string) (parent, key, value *yaml.Node) {
+	if len(path) == 0 {
+		return nil, nil, d.root
+	}
+	parent = d.root
+	for i, name := range path {
+		if parent == nil || parent.Kind != yaml.MappingNode {
+			return nil, nil, nil
+		}
+		key, value = lookup(parent, name)
+		if key == nil {
+			return nil, nil, nil
+		}
+		if i < len(path)-1 {
+			parent = value
+		}
+	}
+	return parent, key, value
+}
+
+// lookup returns the key and value nodes of a key in a mapping.
+// Without an exact match keys are compared case-insensitively, like viper does.
+func lookup(mapping *yaml.Node, name string) (key, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == name {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, name) {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func toNode(value interface{}) (*yaml.Node, error) {
+	if n, ok := value.(*yaml.Node); ok {
+		return n, nil
+	}
+	var n yaml.Node
+	if err := n.Encode(value); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// nest wraps a value into mappings for each key of the path.
+func nest(path []string, n *yaml.Node) *yaml.Node {
+	for i := len(path) - 1; i >= 0; i-- {
+		n = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Tag:     "!!map",
+			Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[i]}, n},
+		}
+	}
+	return n
+}
+
+// Set changes the value at the path of mapping keys. Missing keys are added at the end of their mapping.
+// Values are encoded like yaml.Marshal does, or can be given as *yaml.Node.
+func (d *Document) Set(path []string, value interface{}) error {
+	if len(path) == 0 {
+		return errors.New("cannot set the root of the document")
+	}
+	n, err := toNode(value)
+	if err != nil {
+		return err
+	}
+	if err := d.set(path, n); err != nil {
+		return err
+	}
+	return d.parse()
+}
+
+func (d *Document) set(path []string, n *yaml.Node) error {
+	if d.root == nil {
+		return d.insert(nil, path[0], nest(path[1:], n))
+	}
+	parent := d.root
+	for i, name := range path {
+		key, value := lookup(parent, name)
+		if key == nil {
+			return d.insert(parent, name, nest(path[i+1:], n))
+		}
+		if i == len(path)-1 {
+			return d.replace(parent, key, value, n)
+		}
+		if value.Kind == yaml.AliasNode {
+			return fmt.Errorf("cannot change %s through the alias *%s", strings.Join(path, "."), value.Value)
+		}
+		if value.Kind != yaml.MappingNode {
+			return d.replace(parent, key, value, nest(path[i+1:], n))
+		}
+		parent = value
+	}
+	return nil
+}
+
+// Delete removes the key at the path. It returns false if the key does not exist.
+func (d *Document) Delete(path []string) (bool, error) {
+	parent, key, value := d.find(path)
+	if key == nil {
+		return false, nil
+	}
+	if isFlow(parent) {
+		start := d.offset(key)
+		end, err := d.valueEnd(value, true)
+		if err != nil {
+			return false, err
+		}
+		// Remove the separating comma after the entry, or before it for the last entry
+		if rest := d.skipSpaces(end); rest < len(d.src) && d.src[rest] == ',' {
+			end = d.skipSpaces(rest + 1)
+		} else {
+			before := start
+			for before > 0 && (d.src[before-1] == ' ' || d.src[before-1] == '\t') {
+				before--
+			}
+			if before > 0 && d.src[before-1] == ',' {
+				start = before - 1
+			}
+		}
+		d.splice(start, end, "")
+	} else {
+		start := d.lines[d.headLine(key)-1]
+		end := d.entryEnd(key, value)
+		if !d.onlySpaces(d.lines[key.Line-1], d.offset(key)) {
+			// The key follows a sequence indicator, e.g. "- name: foo"
+			if parent.Content[0] != key || len(parent.Content) < 4 {
+				return false, fmt.Errorf("cannot delete %s from its sequence item", strings.Join(path, "."))
+			}
+			start = d.offset(key)
+			end = d.offset(parent.Content[2])
+		}
+		d.splice(start, end, "")
+	}
+	return true, d.parse()
+}
+
+// Sync makes the value at the path equal to the given one, changing only the nodes that differ.
+// Keys missing in the value are removed from the document, while zero values are not added to it, as both decode the same.
+// Values are compared after decoding them, so equal values written differently, through anchors or merge keys, are not touched.
+// Values inherited through a merge key are overridden in the merging mapping, and mappings merging a changed anchor
+// override the changed keys with their previous values, so that the change does not spread to them.
+func (d *Document) Sync(path []string, value interface{}) error {
+	n, err := toNode(value)
+	if err != nil {
+		return err
+	}
+	merging := d.mergingMappings(d.root, nil)
+	if err := d.sync(path, prune(n)); err != nil {
+		return err
+	}
+	return d.keepMerged(path, merging)
+}
+
+// mergingMapping is a mapping using a merge key, with its decoded value.
+type mergingMapping struct {
+	path  []string
+	value map[string]interface{}
+}
+
+// mergingMappings returns the mappings below a node that use a merge key.
+func (d *Document) mergingMappings(n *yaml.Node, path []string) []mergingMapping {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var found []mergingMapping
+	if key, _ := lookup(n, "<<"); key != nil {
+		value, _ := decode(n).(map[string]interface{})
+		found = append(found, mergingMapping{path: path, value: value})
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		found = append(found, d.mergingMappings(n.Content[i+1], append(path[:len(path):len(path)], n.Content[i].Value))...)
+	}
+	return found
+}
+
+// keepMerged restores the values that mappings outside of the synced path inherited before through a merge key.
+// Keys they did not inherit before are set to null.
+func (d *Document) keepMerged(synced []string, merging []mergingMapping) error {
+	for _, m := range merging {
+		if hasPrefix(m.path, synced) {
+			continue
+		}
+		current, found := d.Get(m.path)
+		if !found || current.Kind != yaml.MappingNode {
+			continue
+		}
+		value, _ := decode(current).(map[string]interface{})
+		var names []string
+		for name := range m.value {
+			names = append(names, name)
+		}
+		for name := range value {
+			if _, ok := m.value[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if key, _ := lookup(current, name); key != nil || reflect.DeepEqual(normalize(m.value[name]), normalize(value[name])) {
+				continue
+			}
+			n, err := toNode(m.value[name])
+			if err != nil {
+				return err
+			}
+			if err := d.Set(append(m.path[:len(m.path):len(m.path)], name), n); err != nil {
+				return err
+			}
+			current, _ = d.Get(m.path)
+		}
+	}
+	return nil
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if !strings.EqualFold(path[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Document) sync(path []string, n *yaml.Node) error {
+	current, found := d.Get(path)
+	if !found || current.Kind == yaml.AliasNode || current.Kind != n.Kind || n.Kind != yaml.MappingNode {
+		if found && equal(current, n) {
+			return nil
+		}
+		if !found && normalize(decode(n)) == nil {
+			return nil
+		}
+		return d.Set(path, n)
+	}
+	if equal(current, n) {
+		return nil
+	}
+	// Values inherited through a merge key
+	inherited, _ := decode(current).(map[string]interface{})
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		name, child := n.Content[i].Value, n.Content[i+1]
+		if key, _ := lookup(current, name); key == nil {
+			if v, ok := lookupDecoded(inherited, name); ok && normalize(v) != nil {
+				// Only overridden if it differs
+				if reflect.DeepEqual(normalize(v), normalize(decode(child))) {
+					continue
+				}
+				if err := d.Set(append(path[:len(path):len(path)], name), child); err != nil {
+					return err
+				}
+				current, _ = d.Get(path)
+				continue
+			}
+		}
+		if err := d.sync(append(path[:len(path):len(path)], name), child); err != nil {
+			return err
+		}
+		current, _ = d.Get(path)
+	}
+	// Remove the keys that are not in the value anymore
+	var removed, overridden []string
+	for i := 0; i+1 < len(current.Content); i += 2 {
+		name := current.Content[i].Value
+		if name == "<<" {
+			continue
+		}
+		if key, _ := lookup(n, name); key == nil && normalize(decode(current.Content[i+1])) != nil {
+			removed = append(removed, name)
+		}
+	}
+	// Inherited keys cannot be removed, they are set to null instead
+	for name, v := range inherited {
+		if key, _ := lookup(current, name); key == nil && normalize(v) != nil {
+			if key, _ := lookup(n, name); key == nil {
+				overridden = append(overridden, name)
+			}
+		}
+	}
+	sort.Strings(overridden)
+	for _, name := range removed {
+		if _, err := d.Delete(append(path[:len(path):len(path)], name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range overridden {
+		if err := d.Set(append(path[:len(path):len(path)], name), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupDecoded returns the value of a key in a decoded mapping, compared case-insensitively like lookup.
+func lookupDecoded(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// prune removes the zero values from the mappings of a node.
+func prune(n *yaml.Node) *yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		pruned := *n
+		pruned.Content = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if normalize(decode(n.Content[i+1])) == nil {
+				continue
+			}
+			pruned.Content = append(pruned.Content, n.Content[i], prune(n.Content[i+1]))
+		}
+		return &pruned
+	case yaml.SequenceNode:
+		pruned := *n
+		pruned.Content = make([]*yaml.Node, len(n.Content))
+		for i, c := range n.Content {
+			pruned.Content[i] = prune(c)
+		}
+		return &pruned
+	}
+	return n
+}
+
+func decode(n *yaml.Node) interface{} {
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return n.Value
+	}
+	return v
+}
+
+// normalize brings a decoded value into a form in which values that read the same are equal.
+// Zero values become nil, scalars strings and keys lower case.
+// Like the weakly typed decoding of the config, a sequence with a single item equals the item itself.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, c := range v {
+			if c = normalize(c); c != nil {
+				m[strings.ToLower(k)] = c
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, c := range v {
+			if c = normalize(c); c != nil {
+				m[strings.ToLower(fmt.Sprint(k))] = c
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		if len(v) == 1 {
+			return normalize(v[0])
+		}
+		s := make([]interface{}, len(v))
+		for i, c := range v {
+			s[i] = normalize(c)
+		}
+		return s
+	default:
+		if reflect.ValueOf(v).IsZero() {
+			return nil
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+func equal(a, b *yaml.Node) bool {
+	return reflect.DeepEqual(normalize(decode(a)), normalize(decode(b)))
+}
+
+func isFlow(n *yaml.Node) bool {
+	return n != nil && n.Style&yaml.FlowStyle != 0
+}
+
+// inline returns whether a value is written on the line of its key.
+func inline(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode || len(n.Content) == 0 || isFlow(n)
+}
+
+func isImplicitNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null" && n.Value == ""
+}
+
+// insert adds a key at the end of a mapping, or of the document if the mapping is nil.
+func (d *Document) insert(mapping *yaml.Node, name string, n *yaml.Node) error {
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+	if isFlow(mapping) {
+		end, err := d.flowEnd(d.offset(mapping))
+		if err != nil {
+			return err
+		}
+		closing := end - 1
+		text := d.renderFlow(key) + ": " + d.renderFlow(n)
+		if len(mapping.Content) > 0 {
+			last, err := d.valueEnd(mapping.Content[len(mapping.Content)-1], true)
+			if err != nil {
+				return err
+			}
+			d.splice(last, last, ", "+text)
+		} else {
+			d.splice(closing, closing, text)
+		}
+		return nil
+	}
+
+	at := len(d.src)
+	indent := 0
+	if mapping != nil {
+		last := len(mapping.Content) - 2
+		at = d.entryEnd(mapping.Content[last], mapping.Content[last+1])
+		indent = mapping.Content[0].Column - 1
+	}
+	text := d.renderEntry(key, n, indent)
+	if at == len(d.src) && at > 0 && d.src[at-1] != '\n' {
+		text = d.newline + text
+	}
+	d.splice(at, at, text)
+	return nil
+}
+
+// replace changes the value of a key in a mapping.
+func (d *Document) replace(mapping, key, value, n *yaml.Node) error {
+	if isFlow(mapping) {
+		start := d.offset(value)
+		end, err := d.valueEnd(value, true)
+		if err != nil {
+			return err
+		}
+		d.splice(start, end, d.renderFlow(n))
+		return nil
+	}
+
+	// Flow collections stay on their line
+	if isFlow(value) && n.Kind != yaml.ScalarNode {
+		start := d.offset(value)
+		end, err := d.valueEnd(value, false)
+		if err != nil {
+			return err
+		}
+		d.splice(start, end, d.renderFlow(n))
+		return nil
+	}
+
+	if inline(n) {
+		if isImplicitNull(value) {
+			colon, err := d.afterColon(key)
+			if err != nil {
+				return err
+			}
+			d.splice(colon, colon, " "+d.renderFlow(n))
+			return nil
+		}
+		if value.Kind == yaml.ScalarNode || value.Kind == yaml.AliasNode || isFlow(value) {
+			// Strings keep their quotes
+			if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && n.Style == 0 && value.Kind == yaml.ScalarNode && value.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+				quoted := *n
+				quoted.Style = value.Style
+				n = &quoted
+			}
+			start := d.offset(value)
+			if end, err := d.valueEnd(value, false); err == nil {
+				d.splice(start, end, d.renderFlow(n))
+				return nil
+			}
+		}
+	}
+
+	// Keys without a value keep their line, including its comment
+	if isImplicitNull(value) {
+		at := len(d.src)
+		text := d.renderBlock(n, key.Column-1+d.indent)
+		if key.Line < len(d.lines) {
+			at = d.lines[key.Line]
+		} else {
+			text = d.newline + text
+		}
+		d.splice(at, at, text)
+		return nil
+	}
+
+	// Rewrite everything after the key
+	colon, err := d.afterColon(key)
+	if err != nil {
+		return err
+	}
+	end := d.entryEnd(key, value)
+	var text string
+	if inline(n) {
+		text = " " + d.renderFlow(n) + d.newline
+	} else {
+		text = d.newline + d.renderBlock(n, key.Column-1+d.indent)
+	}
+	if end == len(d.src) && (end == 0 || d.src[end-1] != '\n') {
+		text = strings.TrimSuffix(text, d.newline)
+	}
+	d.splice(colon, end, text)
+	return nil
+}
+
+func (d *Document) splice(start, end int, text string) {
+	src := make([]byte, 0, len(d.src)-(end-start)+len(text))
+	src = append(src, d.src[:start]...)
+	src = append(src, text...)
+	src = append(src, d.src[end:]...)
+	d.src = src
+}
+
+// offset returns the byte offset at which the text of a node starts, after its anchor and tag.
+func (d *Document) offset(n *yaml.Node) int {
+	o := d.lines[n.Line-1]
+	for col := 1; col < n.Column && o < len(d.src); col++ {
+		_, size := utf8.DecodeRune(d.src[o:])
+		o += size
+	}
+	for o < len(d.src) && (d.src[o] == '&' || d.src[o] == '!') && n.Kind != yaml.AliasNode {
+		for o < len(d.src) && !isSpace(d.src[o]) {
+			o++
+		}
+		o = d.skipSpaces(o)
+	}
+	return o
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isFlowIndicator(c byte) bool {
+	return strings.IndexByte(",[]{}", c) >= 0
+}
+
+func (d *Document) skipSpaces(o int) int {
+	for o < len(d.src) && (d.src[o] == ' ' || d.src[o] == '\t') {
+		o++
+	}
+	return o
+}
+
+func (d *Document) onlySpaces(start, end int) bool {
+	return len(bytes.Trim(d.src[start:end], " \t")) == 0
+}
+
+// valueEnd returns the byte offset after the text of a scalar, alias or flow collection.
+func (d *Document) valueEnd(n *yaml.Node, flow bool) (int, error) {
+	o := d.offset(n)
+	if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+		if !isFlow(n) {
+			return 0, fmt.Errorf("line %d: not a flow collection", n.Line)
+		}
+		return d.flowEnd(o)
+	}
+	return d.scalarEnd(n, o, flow)
+}
+
+func (d *Document) scalarEnd(n *yaml.Node, o int, flow bool) (int, error) {
+	if o >= len(d.src) {
+		return o, nil
+	}
+	switch d.src[o] {
+	case '"':
+		for i := o + 1; i < len(d.src); i++ {
+			switch d.src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("line %d: unterminated string", n.Line)
+	case '\'':
+		for i := o + 1; i < len(d.src); i++ {
+			if d.src[i] == '\'' {
+				if i+1 < len(d.src) && d.src[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("line %d: unterminated string", n.Line)
+	case '|', '>':
+		if n.Kind == yaml.ScalarNode {
+			return 0, fmt.Errorf("line %d: block scalars span several lines", n.Line)
+		}
+	}
+	i := o
+	for ; i < len(d.src); i++ {
+		c := d.src[i]
+		if c == '\n' || (c == '#' && i > o && isSpace(d.src[i-1])) || (flow && isFlowIndicator(c)) {
+			break
+		}
+		if c == ':' && (i+1 == len(d.src) || isSpace(d.src[i+1]) || (flow && isFlowIndicator(d.src[i+1]))) {
+			break
+		}
+	}
+	end := o + len(bytes.TrimRight(d.src[o:i], " \t\r"))
+	// Plain scalars continued on the next lines are folded into one
+	if n.Kind == yaml.ScalarNode && n.Style == 0 && n.Tag != "!!null" && string(d.src[o:end]) != n.Value {
+		return 0, fmt.Errorf("line %d: scalar spans several lines", n.Line)
+	}
+	return end, nil
+}
+
+// flowEnd returns the byte offset after the flow collection starting at o.
+func (d *Document) flowEnd(o int) (int, error) {
+	depth := 0
+	for i := o; i < len(d.src); i++ {
+		switch d.src[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case '"', '\'':
+			end, err := d.scalarEnd(&yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle}, i, true)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		case '#':
+			if i > 0 && isSpace(d.src[i-1]) {
+				for i < len(d.src) && d.src[i] != '\n' {
+					i++
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated flow collection at byte %d", o)
+}
+
+// afterColon returns the byte offset after the colon following a key.
+func (d *Document) afterColon(key *yaml.Node) (int, error) {
+	end, err := d.scalarEnd(key, d.offset(key), false)
+	if err != nil {
+		return 0, err
+	}
+	end = d.skipSpaces(end)
+	if end >= len(d.src) || d.src[end] != ':' {
+		return 0, fmt.Errorf("line %d: no colon after key %s", key.Line, key.Value)
+	}
+	return end + 1, nil
+}
+
+// headLine returns the first line of the comment directly above a key, or the line of the key.
+func (d *Document) headLine(key *yaml.Node) int {
+	line := key.Line
+	if key.HeadComment == "" {
+		return line
+	}
+	for line > 1 {
+		start := d.lines[line-2]
+		text := bytes.TrimSpace(d.src[start:d.lines[line-1]])
+		if len(text) == 0 || text[0] != '#' {
+			break
+		}
+		line--
+	}
+	return line
+}
+
+func lastLine(n *yaml.Node) int {
+	line := n.Line
+	for _, c := range n.Content {
+		if l := lastLine(c); l > line {
+			line = l
+		}
+	}
+	return line
+}
+
+func (d *Document) lineIndent(line int) (int, bool) {
+	start := d.lines[line-1]
+	end := len(d.src)
+	if line < len(d.lines) {
+		end = d.lines[line]
+	}
+	text := d.src[start:end]
+	trimmed := bytes.TrimLeft(text, " ")
+	blank := len(bytes.TrimSpace(trimmed)) == 0
+	return len(text) - len(trimmed), blank
+}
+
+// entryEnd returns the byte offset of the line after an entry of a block mapping.
+// Besides the lines of its nodes, the entry includes the following lines that are indented deeper than its key.
+func (d *Document) entryEnd(key, value *yaml.Node) int {
+	indent := key.Column - 1
+	last := lastLine(value)
+	if key.Line > last {
+		last = key.Line
+	}
+	for line := last + 1; line <= len(d.lines); line++ {
+		i, blank := d.lineIndent(line)
+		if blank {
+			continue
+		}
+		if i <= indent {
+			break
+		}
+		last = line
+	}
+	if last < len(d.lines) {
+		return d.lines[last]
+	}
+	return len(d.src)
+}
+
+// renderFlow renders a value on a single line.
+func (d *Document) renderFlow(n *yaml.Node) string {
+	c := *n
+	if c.Kind == yaml.ScalarNode {
+		if strings.ContainsAny(c.Value, "\n\r") || (c.Style == 0 && strings.ContainsAny(c.Value, ",[]{}")) {
+			c.Style = yaml.DoubleQuotedStyle
+		}
+	} else {
+		c.Style |= yaml.FlowStyle
+	}
+	out, err := yaml.Marshal(&c)
+	if err != nil {
+		return n.Value
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+
+// renderBlock renders a collection on its own lines with the given indentation.
+func (d *Document) renderBlock(n *yaml.Node, indent int) string {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(d.indent)
+	enc.Encode(n)
+	enc.Close()
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		if line != "" {
+			b.WriteString(strings.Repeat(" ", indent))
+		}
+		b.WriteString(line)
+		b.WriteString(d.newline)
+	}
+	return b.String()
+}
+
+func (d *Document) renderEntry(key, n *yaml.Node, indent int) string {
+	text := strings.Repeat(" ", indent) + d.renderFlow(key) + ":"
+	if inline(n) {
+		return text + " " + d.renderFlow(n) + d.newline
+	}
+	return text + d.newline + d.renderBlock(n, indent+d.indent)
+}
@@ -0,0 +1,225 @@
+package yamledit
+
+import (
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func load(t *testing.T, name string) *Document {
+	src, err := os.ReadFile(path.Join("testdata", name+".yml"))
+	assert.NoError(t, err)
+	d, err := Parse(src)
+	assert.NoError(t, err)
+	return d
+}
+
+// decodeAll returns the top level keys of a document with their decoded values.
+func decodeAll(t *testing.T, d *Document) map[string]interface{} {
+	var v map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(d.Bytes(), &v))
+	return v
+}
+
+// Every fixture is edited and compared with testdata/<fixture>.<case>.golden.yml
+func TestEdits(t *testing.T) {
+	cases := []struct {
+		fixture string
+		name    string
+		edit    func(d *Document) error
+	}{
+		{"config", "set_key", func(d *Document) error {
+			return d.Set([]string{"backends", "nas", "key"}, "new-key")
+		}},
+		{"config", "add_key_file", func(d *Document) error {
+			return d.Set([]string{"backends", "nas", "keyFile"}, "keys/nas.key")
+		}},
+		{"config", "add_backend", func(d *Document) error {
+			return d.Set([]string{"backends", "s3"}, map[string]string{"type": "s3", "path": "s3.amazonaws.com/bucket"})
+		}},
+		{"config", "add_nested", func(d *Document) error {
+			return d.Set([]string{"locations", "home", "options", "forget", "keep-daily"}, 7)
+		}},
+		{"config", "replace_flow_sequence", func(d *Document) error {
+			return d.Set([]string{"locations", "home", "to"}, []string{"nas"})
+		}},
+		{"config", "replace_block_sequence", func(d *Document) error {
+			return d.Set([]string{"locations", "db", "from"}, []string{"/var/lib/db", "/var/backups/db"})
+		}},
+		{"config", "replace_alias", func(d *Document) error {
+			return d.Set([]string{"locations", "home", "hooks"}, map[string][]string{"before": {"echo start"}})
+		}},
+		{"config", "replace_sequence_with_scalar", func(d *Document) error {
+			return d.Set([]string{"locations", "db", "from"}, "/var/lib/db")
+		}},
+		{"config", "delete_location", func(d *Document) error {
+			_, err := d.Delete([]string{"locations", "db"})
+			return err
+		}},
+		{"config", "delete_last_key", func(d *Document) error {
+			_, err := d.Delete([]string{"backends", "b2", "env"})
+			return err
+		}},
+		{"config", "delete_in_sequence_item", func(d *Document) error {
+			_, err := d.Delete([]string{"locations", "db", "notifications"})
+			return err
+		}},
+		{"config", "sync", func(d *Document) error {
+			backends := map[string]interface{}{
+				"nas": map[string]interface{}{"type": "local", "path": "/mnt/backup", "key": "old-key"},
+				"b2":  map[string]interface{}{"type": "b2", "path": "bucket:/backups", "env": map[string]string{"B2_ACCOUNT_ID": "abc"}, "requireKey": false},
+			}
+			return d.Sync([]string{"backends"}, backends)
+		}},
+		{"merge", "sync_merging", func(d *Document) error {
+			// The inherited type and env are left as they are, the path is overridden
+			b := map[string]interface{}{"type": "b2", "path": "bucket:/backups", "env": map[string]string{"B2_ACCOUNT_ID": "abc"}, "key": "secret"}
+			if err := d.Sync([]string{"backends", "b"}, b); err != nil {
+				return err
+			}
+			// Inherited keys that are gone are set to null
+			return d.Sync([]string{"backends", "c"}, map[string]interface{}{"type": "b2", "path": "bucket:/c", "key": "own"})
+		}},
+		{"merge", "sync_anchor", func(d *Document) error {
+			a := map[string]interface{}{"type": "s3", "path": "bucket:/a", "env": map[string]string{"B2_ACCOUNT_ID": "abc"}, "key": "secret"}
+			return d.Sync([]string{"backends", "a"}, a)
+		}},
+		{"flow", "set", func(d *Document) error {
+			if err := d.Set([]string{"backends", "nas", "key"}, "secret"); err != nil {
+				return err
+			}
+			if err := d.Set([]string{"backends", "nas", "path"}, "/mnt/nas"); err != nil {
+				return err
+			}
+			return d.Set([]string{"backends", "empty", "type"}, "local")
+		}},
+		{"flow", "delete", func(d *Document) error {
+			if _, err := d.Delete([]string{"backends", "nas", "path"}); err != nil {
+				return err
+			}
+			_, err := d.Delete([]string{"backends", "empty"})
+			return err
+		}},
+		{"flow", "replace_sequence", func(d *Document) error {
+			return d.Set([]string{"paths"}, []string{"a,b", "c"})
+		}},
+		{"empty", "set", func(d *Document) error {
+			if err := d.Set([]string{"backends", "nas", "type"}, "local"); err != nil {
+				return err
+			}
+			return d.Set([]string{"locations", "home", "to"}, []string{"nas"})
+		}},
+		{"crlf", "set", func(d *Document) error {
+			return d.Set([]string{"backends", "nas", "key"}, "secret")
+		}},
+		{"indent", "set", func(d *Document) error {
+			if err := d.Set([]string{"backends", "nas", "key"}, "secret"); err != nil {
+				return err
+			}
+			return d.Set([]string{"backends", "b2"}, map[string]string{"type": "b2"})
+		}},
+		{"compact", "set", func(d *Document) error {
+			if err := d.Set([]string{"backends", "a", "key"}, "def"); err != nil {
+				return err
+			}
+			return d.Set([]string{"locations", "foo", "cron"}, "0 3 * * *")
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.fixture+"/"+c.name, func(t *testing.T) {
+			d := load(t, c.fixture)
+			assert.NoError(t, c.edit(d))
+
+			golden := path.Join("testdata", c.fixture+"."+c.name+".golden.yml")
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, d.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(d.Bytes()))
+
+			// The result must still be valid
+			_, err = Parse(d.Bytes())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// Documents that are not edited, or synced with their own values, are left byte for byte as they are.
+func TestRoundTrip(t *testing.T) {
+	fixtures, err := filepath.Glob(path.Join("testdata", "*.yml"))
+	assert.NoError(t, err)
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".yml")
+		if strings.HasSuffix(name, ".golden") {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(fixture)
+			assert.NoError(t, err)
+			d, err := Parse(src)
+			assert.NoError(t, err)
+			assert.Equal(t, string(src), string(d.Bytes()))
+
+			for key, value := range decodeAll(t, d) {
+				assert.NoError(t, d.Sync([]string{key}, value))
+			}
+			assert.Equal(t, string(src), string(d.Bytes()))
+		})
+	}
+}
+
+func TestSetThroughAlias(t *testing.T) {
+	d := load(t, "config")
+	err := d.Set([]string{"locations", "home", "hooks", "before"}, []string{"echo start"})
+	assert.EqualError(t, err, "cannot change locations.home.hooks.before through the alias *hooks")
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	d, err := Parse([]byte("backends:\n  nas:\n    KeyFile: old\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, d.Set([]string{"backends", "nas", "keyFile"}, "new"))
+	assert.Equal(t, "backends:\n  nas:\n    KeyFile: new\n", string(d.Bytes()))
+}
+
+func TestDelete(t *testing.T) {
+	d := load(t, "config")
+	found, err := d.Delete([]string{"locations", "missing"})
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	found, err = d.Delete([]string{"locations", "home", "forget"})
+	assert.NoError(t, err)
+	assert.True(t, found)
+	_, ok := d.Get([]string{"locations", "home", "forget"})
+	assert.False(t, ok)
+}
+
+func TestSave(t *testing.T) {
+	file := path.Join(t.TempDir(), "config.yml")
+	assert.NoError(t, os.WriteFile(file, []byte("version: 2 # current\n"), 0600))
+	d, err := Load(file)
+	assert.NoError(t, err)
+	assert.NoError(t, d.Set([]string{"backends", "nas", "type"}, "local"))
+	assert.NoError(t, d.Save(file))
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "version: 2 # current\nbackends:\n  nas:\n    type: local\n", string(content))
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(file))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
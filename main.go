@@ -26,12 +26,12 @@ import (
 )
 
 func handleCtrlC() {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		sig := <-c
 		fmt.Println("Signal:", sig)
-		lock.Unlock()
+		lock.ReleaseAll()
 		os.Exit(0)
 	}()
 }